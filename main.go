@@ -1,6 +1,9 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
@@ -10,14 +13,20 @@ import (
 	"simple-server/src/backend/handlers"
 	"simple-server/src/backend/middleware"
 	"simple-server/src/backend/services"
-	"simple-server/src/backend/utils"
-	"strings"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
 
 func main() {
+	// Helper command for generating users file entries
+	if len(os.Args) > 1 && os.Args[1] == "hash-password" {
+		hashPassword(os.Args[2:])
+		return
+	}
+
 	// Load configuration
 	cfg, err := config.LoadConfig()
 	if err != nil {
@@ -59,10 +68,113 @@ func main() {
 
 	// Initialize services
 	fileService := services.NewFileService(cfg)
+	uploadService, err := services.NewUploadService(cfg)
+	if err != nil {
+		logger.Fatalf("Invalid upload config: %v", err)
+	}
+
+	scanner, err := services.NewScanner(cfg)
+	if err != nil {
+		logger.Fatalf("Invalid scan config: %v", err)
+	}
+	if scanner != nil {
+		uploadService.SetScanner(scanner, func(filename string, err error) {
+			logger.WithError(err).WithFields(logrus.Fields{
+				"filename": filename,
+				"policy":   cfg.Scan.FailurePolicy,
+			}).Warn("Malware scan failed")
+		})
+	}
+
+	userService, err := services.NewUserService(cfg)
+	if err != nil {
+		logger.Fatalf("Failed to load users: %v", err)
+	}
+
+	sessionService, err := services.NewSessionService(cfg)
+	if err != nil {
+		logger.Fatalf("Failed to initialize sessions: %v", err)
+	}
+
+	tokenService, err := services.NewTokenService(cfg)
+	if err != nil {
+		logger.Fatalf("Failed to load API tokens: %v", err)
+	}
+
+	shareService, err := services.NewShareService(cfg)
+	if err != nil {
+		logger.Fatalf("Failed to initialize share links: %v", err)
+	}
+
+	dropBoxService, err := services.NewDropBoxService(cfg)
+	if err != nil {
+		logger.Fatalf("Failed to load drop boxes: %v", err)
+	}
+
+	var tusService *services.TusService
+	if cfg.Tus.Enabled {
+		tusService, err = services.NewTusService(cfg, uploadService)
+		if err != nil {
+			logger.Fatalf("Failed to load resumable uploads: %v", err)
+		}
+		if cfg.Tus.CleanupInterval > 0 {
+			go cleanupTusUploads(tusService, cfg.Tus.CleanupInterval, logger)
+		}
+	}
+
+	checksumService, err := services.NewChecksumService(cfg)
+	if err != nil {
+		logger.Fatalf("Failed to load checksums: %v", err)
+	}
+	uploadService.AddTracker(checksumService)
+
+	var moderationService *services.ModerationService
+	if cfg.Moderation.Enabled {
+		moderationService, err = services.NewModerationService(cfg, uploadService)
+		if err != nil {
+			logger.Fatalf("Failed to load moderation state: %v", err)
+		}
+		uploadService.AddTracker(moderationService)
+	}
+
+	var quotaService *services.QuotaService
+	if cfg.Quota.Enabled {
+		quotaService, err = services.NewQuotaService(cfg)
+		if err != nil {
+			logger.Fatalf("Failed to measure storage usage: %v", err)
+		}
+		uploadService.SetQuota(quotaService)
+		uploadService.AddTracker(quotaService)
+	}
+
+	var progressService *services.ProgressService
+	if cfg.Progress.Enabled {
+		progressService = services.NewProgressService(cfg)
+	}
+
+	var auditService *services.AuditService
+	if cfg.Audit.Enabled {
+		auditService, err = services.NewAuditService(cfg)
+		if err != nil {
+			logger.Fatalf("Failed to open audit log: %v", err)
+		}
+		defer auditService.Close()
+	}
 
 	// Initialize handlers
 	fileHandler := handlers.NewFileHandler(fileService)
-	uploadHandler := handlers.NewUploadHandler(cfg, logger)
+	downloadHandler := handlers.NewDownloadHandler(cfg, fileService, checksumService)
+	checksumHandler := handlers.NewChecksumHandler(cfg, fileService, checksumService, logger)
+	// Raw uploads answer with a share link when share links can be downloaded
+	var uploadShares *services.ShareService
+	if cfg.Sharing.Enabled {
+		uploadShares = shareService
+	}
+	uploadHandler := handlers.NewUploadHandler(cfg, uploadService, fileService, uploadShares, progressService, logger)
+	authHandler := handlers.NewAuthHandler(cfg, userService, sessionService, logger)
+	shareHandler := handlers.NewShareHandler(cfg, shareService, fileService, checksumService, logger)
+	tokenHandler := handlers.NewTokenHandler(userService, tokenService, logger)
+	dropBoxHandler := handlers.NewDropBoxHandler(cfg, dropBoxService, uploadService, logger)
 
 	// Set Gin mode
 	if cfg.Logging.Level == "debug" {
@@ -74,23 +186,112 @@ func main() {
 	// Create Gin router
 	router := gin.New()
 
+	// Only trust forwarding headers from configured proxies when resolving client IPs
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		logger.Fatalf("Invalid trusted proxies: %v", err)
+	}
+	router.RemoteIPHeaders = cfg.Server.RemoteIPHeaders
+
 	// Add middleware
 	router.Use(middleware.LoggingMiddleware(logger))
 	router.Use(middleware.SecurityMiddleware(cfg))
-	router.Use(middleware.CORSMiddleware())
+	router.Use(middleware.CORSMiddleware(corsRules(cfg)))
 	router.Use(gin.Recovery())
+	if auditService != nil {
+		router.Use(middleware.AuditMiddleware(auditService, auditActions, logger))
+	}
+	var resolvers []middleware.IdentityResolver
+	var clientCertService *services.ClientCertService
+	if cfg.Security.ClientCerts.Enabled {
+		clientCertService, err = services.NewClientCertService(cfg, userService)
+		if err != nil {
+			logger.Fatalf("Failed to load client certificate CAs: %v", err)
+		}
+		// A verified certificate wins over cookies and headers sent along with it
+		resolvers = append(resolvers, middleware.ClientCertResolver(clientCertService))
+	}
+	resolvers = append(resolvers,
+		middleware.TokenResolver(userService, tokenService),
+		middleware.SessionResolver(cfg, userService, sessionService),
+	)
+	if cfg.Security.BasicAuth.Enabled {
+		htpasswdService, err := services.NewHtpasswdService(cfg)
+		if err != nil {
+			logger.Fatalf("Failed to load htpasswd file: %v", err)
+		}
+		resolvers = append(resolvers, middleware.BasicAuthResolver(htpasswdService, cfg.Security.BasicAuth.Realm))
+	}
+	router.Use(middleware.AuthMiddleware(resolvers...))
+
+	groups, err := newRouteGroups(cfg, logger)
+	if err != nil {
+		logger.Fatalf("Invalid route group config: %v", err)
+	}
 
 	// Set up static file service
-	setupStaticRoutes(router, cfg)
+	setupStaticRoutes(router, cfg, groups, downloadHandler)
+
+	// Set up authentication routes
+	setupAuthRoutes(router, cfg, groups, authHandler)
 
 	// Set up API routes
-	setupAPIRoutes(router, fileHandler, uploadHandler)
+	setupAPIRoutes(router, groups, fileHandler, uploadHandler, tokenHandler, checksumHandler)
 
 	// Set up file service routes
-	setupFileRoutes(router, cfg)
+	setupFileRoutes(router, groups, downloadHandler)
+
+	// Set up share link routes
+	if cfg.Sharing.Enabled {
+		setupShareRoutes(router, groups, shareHandler)
+	}
+
+	// Set up drop box routes
+	if cfg.DropBox.Enabled {
+		setupDropBoxRoutes(router, groups, dropBoxHandler)
+	}
+
+	// Set up resumable upload routes
+	if tusService != nil {
+		setupTusRoutes(router, groups, handlers.NewTusHandler(cfg, tusService, logger))
+	}
+
+	// Set up audit log routes
+	if auditService != nil {
+		setupAuditRoutes(router, groups, handlers.NewAuditHandler(auditService, logger))
+	}
+
+	// Set up moderation routes
+	if moderationService != nil {
+		setupModerationRoutes(router, groups, handlers.NewModerationHandler(cfg, moderationService, logger))
+	}
+
+	// Set up upload progress routes
+	if progressService != nil {
+		setupProgressRoutes(router, groups, handlers.NewProgressHandler(progressService, logger))
+	}
+
+	// Set up quota routes
+	if quotaService != nil {
+		setupQuotaRoutes(router, groups, handlers.NewQuotaHandler(quotaService))
+	}
+
+	// Prepare TLS before printing startup info so certificate details can be shown
+	var certManager *services.CertificateManager
+	var acmeManager *services.ACMEManager
+	if cfg.Server.TLS.Enabled && cfg.Server.TLS.ACME.Enabled {
+		acmeManager, err = services.NewACMEManager(cfg)
+		if err != nil {
+			logger.Fatalf("Failed to initialize ACME: %v", err)
+		}
+	} else if cfg.Server.TLS.Enabled {
+		certManager, err = services.NewCertificateManager(cfg)
+		if err != nil {
+			logger.Fatalf("Failed to load TLS certificate: %v", err)
+		}
+	}
 
 	// Print startup info
-	printStartupInfo(cfg, logger)
+	printStartupInfo(cfg, logger, certManager, acmeManager)
 
 	// Start server
 	server := &http.Server{
@@ -101,7 +302,34 @@ func main() {
 	}
 
 	logger.Infof("Server starting on %s", cfg.GetListenAddr())
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	if acmeManager != nil || certManager != nil {
+		if acmeManager != nil {
+			server.TLSConfig = acmeManager.TLSConfig()
+
+			// HTTP-01 challenges need the HTTP listener even without redirects
+			go startRedirectServer(cfg, logger, acmeManager)
+		} else {
+			server.TLSConfig = &tls.Config{
+				GetCertificate: certManager.GetCertificate,
+				MinVersion:     tls.VersionTLS12,
+			}
+
+			if cfg.Server.TLS.RedirectHTTP {
+				go startRedirectServer(cfg, logger, nil)
+			}
+		}
+
+		// Requests without a certificate are still accepted; route groups decide whether they need one
+		if clientCertService != nil {
+			server.TLSConfig.ClientAuth = tls.VerifyClientCertIfGiven
+			server.TLSConfig.ClientCAs = clientCertService.CAPool()
+		}
+
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
 		logger.Fatalf("Failed to start server: %v", err)
 	}
 }
@@ -152,142 +380,401 @@ func copyFile(src, dst string, logger *logrus.Logger) {
 	}
 }
 
+// startRedirectServer runs a plain HTTP listener that redirects to HTTPS.
+// With ACME it also answers HTTP-01 challenges.
+func startRedirectServer(cfg *config.Config, logger *logrus.Logger, acmeManager *services.ACMEManager) {
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		if cfg.Server.Port != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(cfg.Server.Port))
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+	if acmeManager != nil {
+		if !cfg.Server.TLS.RedirectHTTP {
+			handler = http.NotFoundHandler()
+		}
+		handler = acmeManager.HTTPHandler(handler)
+	}
+
+	redirect := &http.Server{
+		Addr:         cfg.GetRedirectListenAddr(),
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		Handler:      handler,
+	}
+
+	logger.Infof("HTTP redirect listener starting on %s", cfg.GetRedirectListenAddr())
+	if err := redirect.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logger.Errorf("HTTP redirect listener failed: %v", err)
+	}
+}
+
+// routeGroupPrefixes maps the route group names used in config to their URL prefixes
+var routeGroupPrefixes = map[string][]string{
+	config.RouteGroupAPI:     {"/api"},
+	config.RouteGroupUpload:  {"/upload"},
+	config.RouteGroupFiles:   {"/files"},
+	config.RouteGroupPrivate: {"/private-files"},
+	config.RouteGroupShare:   {"/s"},
+	config.RouteGroupDrop:    {"/drop"},
+}
+
+// auditActions maps the routes recorded in the audit log to their action
+var auditActions = map[string]string{
+	"POST /upload":                  services.AuditUpload,
+	"POST /upload/batch":            services.AuditUpload,
+	"PUT /upload/:name":             services.AuditUpload,
+	"POST /upload/tus":              services.AuditUpload,
+	"PATCH /upload/tus/:id":         services.AuditUpload,
+	"POST /drop/:token":             services.AuditUpload,
+	"DELETE /upload/tus/:id":        services.AuditDelete,
+	"GET /files/*filepath":          services.AuditDownload,
+	"GET /s/:token":                 services.AuditDownload,
+	"HEAD /s/:token":                services.AuditDownload,
+	"GET /api/markdown-content":     services.AuditDownload,
+	"GET /api/list-files":           services.AuditList,
+	"GET /api/search":               services.AuditSearch,
+	"GET /private-files/*filepath":  services.AuditPrivate,
+	"HEAD /private-files/*filepath": services.AuditPrivate,
+
+	"POST /api/admin/incoming/approve":      services.AuditModerate,
+	"POST /api/admin/incoming/bulk-approve": services.AuditModerate,
+	"POST /api/admin/incoming/reject":       services.AuditModerate,
+}
+
+// corsRules resolves the route groups of the configured CORS policies to URL prefixes
+func corsRules(cfg *config.Config) []middleware.CORSRule {
+	var rules []middleware.CORSRule
+	for _, policy := range cfg.CORS.Policies {
+		if len(policy.AllowedMethods) == 0 {
+			policy.AllowedMethods = []string{"GET", "POST", "OPTIONS"}
+		}
+		if len(policy.AllowedHeaders) == 0 {
+			policy.AllowedHeaders = []string{"Content-Type", "Authorization"}
+		}
+
+		rule := middleware.CORSRule{Policy: policy}
+		for _, group := range policy.Routes {
+			if group == "*" {
+				rule.Prefixes = []string{""}
+				break
+			}
+			rule.Prefixes = append(rule.Prefixes, routeGroupPrefixes[group]...)
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// routeGroups builds the middleware chain of each named route group from config
+type routeGroups struct {
+	config    *config.Config
+	ipFilters map[string][]gin.HandlerFunc
+	limits    map[string][]gin.HandlerFunc
+}
+
+// newRouteGroups prepares the stateful per-group middleware
+func newRouteGroups(cfg *config.Config, logger *logrus.Logger) (*routeGroups, error) {
+	g := &routeGroups{
+		config:    cfg,
+		ipFilters: make(map[string][]gin.HandlerFunc),
+		limits:    make(map[string][]gin.HandlerFunc),
+	}
+
+	for _, rule := range cfg.Security.IPRules {
+		filter, err := middleware.IPFilterMiddleware(rule, logger)
+		if err != nil {
+			return nil, err
+		}
+		for _, group := range rule.Routes {
+			g.ipFilters[group] = append(g.ipFilters[group], filter)
+		}
+	}
+
+	if cfg.RateLimit.Enabled {
+		api := middleware.NewRateLimiter(cfg.RateLimit.API).Middleware()
+		upload := middleware.NewRateLimiter(cfg.RateLimit.Upload).Middleware()
+		download := middleware.NewRateLimiter(cfg.RateLimit.Download).Middleware()
+
+		g.limits[config.RouteGroupAPI] = []gin.HandlerFunc{api}
+		g.limits[config.RouteGroupUpload] = []gin.HandlerFunc{upload}
+		g.limits[config.RouteGroupDrop] = []gin.HandlerFunc{upload}
+		for _, group := range []string{config.RouteGroupFiles, config.RouteGroupPrivate, config.RouteGroupShare} {
+			g.limits[group] = []gin.HandlerFunc{download}
+		}
+
+		// Downloads from all groups count against one per-client cap
+		if cfg.RateLimit.MaxConcurrentDownloads > 0 {
+			concurrent := middleware.NewConcurrencyLimiter(cfg.RateLimit.MaxConcurrentDownloads).Middleware()
+			for _, group := range []string{config.RouteGroupFiles, config.RouteGroupPrivate, config.RouteGroupShare} {
+				g.limits[group] = append(g.limits[group], concurrent)
+			}
+		}
+	}
+
+	if cfg.Bandwidth.Enabled {
+		throttle := middleware.NewBandwidthLimiter(cfg.Bandwidth, cfg.Server.WriteTimeout).Middleware()
+		for _, group := range []string{config.RouteGroupFiles, config.RouteGroupPrivate, config.RouteGroupShare} {
+			g.limits[group] = append(g.limits[group], throttle)
+		}
+	}
+
+	return g, nil
+}
+
+// guards returns the IP rules and rate limits of a route group, without its authentication
+func (g *routeGroups) guards(group string) []gin.HandlerFunc {
+	var chain []gin.HandlerFunc
+	chain = append(chain, g.ipFilters[group]...)
+	chain = append(chain, g.ipFilters["*"]...)
+	chain = append(chain, g.limits[group]...)
+	return chain
+}
+
+// middleware returns the middleware configured for a route group
+func (g *routeGroups) middleware(group string) []gin.HandlerFunc {
+	// IP rules are checked before any credentials
+	chain := g.guards(group)
+
+	clientCerts := g.config.Security.ClientCerts
+	basicAuth := g.config.Security.BasicAuth
+	if clientCerts.Enabled && config.HasRouteGroup(clientCerts.RequireFor, group) {
+		chain = append(chain, middleware.RequireClientCert())
+	} else if basicAuth.Enabled && config.HasRouteGroup(basicAuth.RequireFor, group) {
+		chain = append(chain, middleware.RequireBasicAuth(basicAuth.Realm))
+	} else if g.config.Auth.Enabled && config.HasRouteGroup(g.config.Auth.RequireFor, group) {
+		chain = append(chain, middleware.RequireAuth())
+	}
+
+	// API tokens only reach the route groups their scopes cover
+	switch group {
+	case config.RouteGroupAPI, config.RouteGroupFiles, config.RouteGroupPrivate:
+		chain = append(chain, middleware.RequireScope(services.ScopeRead))
+	case config.RouteGroupUpload:
+		chain = append(chain, middleware.RequireScope(services.ScopeUpload))
+	}
+
+	return chain
+}
+
 // setupStaticRoutes sets static file routes
-func setupStaticRoutes(router *gin.Engine, cfg *config.Config) {
+func setupStaticRoutes(router *gin.Engine, cfg *config.Config, groups *routeGroups, downloadHandler *handlers.DownloadHandler) {
 	// Static file service (public directory)
 	router.Static("/public", "./public")
 	router.StaticFile("/", "./public/index.html")
+	router.StaticFile("/login", "./public/login.html")
 
 	// Direct access to private files
-	router.Static("/private-files", cfg.Storage.PrivateDir)
+	if cfg.Storage.ExposePrivateDir {
+		private := router.Group("/private-files", groups.middleware(config.RouteGroupPrivate)...)
+		private.GET("/*filepath", downloadHandler.ServePrivateFile)
+		private.HEAD("/*filepath", downloadHandler.ServePrivateFile)
+	}
+}
+
+// setupAuthRoutes sets login and logout routes. They share the IP rules and
+// rate limits of the API group but must stay reachable without a login.
+func setupAuthRoutes(router *gin.Engine, cfg *config.Config, groups *routeGroups, authHandler *handlers.AuthHandler) {
+	api := router.Group("/api", groups.guards(config.RouteGroupAPI)...)
+	{
+		api.POST("/login", middleware.NewRateLimiter(cfg.Auth.LoginLimit).Middleware(), authHandler.Login)
+		api.POST("/logout", authHandler.Logout)
+		api.GET("/me", authHandler.CurrentUser)
+	}
 }
 
 // setupAPIRoutes sets API routes
-func setupAPIRoutes(router *gin.Engine, fileHandler *handlers.FileHandler, uploadHandler *handlers.UploadHandler) {
-	api := router.Group("/api")
+func setupAPIRoutes(router *gin.Engine, groups *routeGroups, fileHandler *handlers.FileHandler, uploadHandler *handlers.UploadHandler, tokenHandler *handlers.TokenHandler, checksumHandler *handlers.ChecksumHandler) {
+	api := router.Group("/api", groups.middleware(config.RouteGroupAPI)...)
 	{
 		api.GET("/list-files", fileHandler.ListFiles)
 		api.GET("/markdown-content", fileHandler.GetMarkdownContent)
 		api.GET("/search", fileHandler.SearchFiles)
+		api.GET("/checksum", checksumHandler.GetChecksum)
+
+		api.POST("/tokens", tokenHandler.CreateToken)
+		api.GET("/tokens", tokenHandler.ListTokens)
+		api.DELETE("/tokens/:id", tokenHandler.RevokeToken)
 	}
 
 	// Upload route
-	router.POST("/upload", uploadHandler.UploadFile)
+	upload := router.Group("/upload", groups.middleware(config.RouteGroupUpload)...)
+	upload.Use(uploadHandler.TrackProgress)
+	upload.POST("", uploadHandler.UploadFile)
+	upload.POST("/batch", uploadHandler.UploadBatch)
+	upload.PUT("/:name", uploadHandler.PutFile)
 }
 
 // setupFileRoutes sets file access routes
-func setupFileRoutes(router *gin.Engine, cfg *config.Config) {
-	// File browsing and download
-	router.GET("/files/*filepath", func(c *gin.Context) {
-		filePath := c.Param("filepath")
+func setupFileRoutes(router *gin.Engine, groups *routeGroups, downloadHandler *handlers.DownloadHandler) {
+	files := router.Group("/files", groups.middleware(config.RouteGroupFiles)...)
 
-		// Use path handling function from utils package
-		cleanPath := utils.SanitizePath(filePath)
-		fullPath := filepath.Join(cfg.Storage.UploadDir, cleanPath)
-
-		// Get absolute path for security check
-		absUploadDir, err := filepath.Abs(cfg.Storage.UploadDir)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
-			return
-		}
-
-		absFullPath, err := filepath.Abs(fullPath)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
-			return
-		}
+	// File browsing and download
+	files.GET("/*filepath", downloadHandler.ServeFile)
 
-		// Security check: ensure path is within upload directory
-		if !strings.HasPrefix(absFullPath+string(filepath.Separator), absUploadDir+string(filepath.Separator)) {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
-			return
-		}
+	// File browser homepage
+	files.GET("", downloadHandler.ServeFileBrowser)
+}
 
-		// Check if it is the incoming directory
-		absIncomingDir, _ := filepath.Abs(cfg.Storage.IncomingDir)
-		if strings.HasPrefix(absFullPath+string(filepath.Separator), absIncomingDir+string(filepath.Separator)) {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
-			return
-		}
+// setupShareRoutes sets share link management and download routes
+func setupShareRoutes(router *gin.Engine, groups *routeGroups, shareHandler *handlers.ShareHandler) {
+	api := router.Group("/api", groups.middleware(config.RouteGroupAPI)...)
+	{
+		api.POST("/share", shareHandler.CreateShare)
+		api.GET("/shares", shareHandler.ListShares)
+		api.DELETE("/shares/:id", middleware.RequireScope(services.ScopeDelete), shareHandler.RevokeShare)
+	}
 
-		// Check if file/directory exists
-		info, err := os.Stat(fullPath)
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
-			return
-		}
+	share := router.Group("/s", groups.middleware(config.RouteGroupShare)...)
+	share.GET("/:token", shareHandler.Download)
+	share.HEAD("/:token", shareHandler.Download)
+}
 
-		if info.IsDir() {
-			// Check if index.html exists
-			indexPath := filepath.Join(fullPath, "index.html")
-			if _, err := os.Stat(indexPath); err == nil {
-				c.File(indexPath)
-				return
-			}
-			// Otherwise return file browser
-			c.File("./public/file-browser.html")
-			return
-		}
+// setupDropBoxRoutes sets drop box management and upload routes
+func setupDropBoxRoutes(router *gin.Engine, groups *routeGroups, dropBoxHandler *handlers.DropBoxHandler) {
+	api := router.Group("/api", groups.middleware(config.RouteGroupAPI)...)
+	api.Use(middleware.RequireScope(services.ScopeUpload))
+	{
+		api.POST("/dropboxes", dropBoxHandler.CreateDropBox)
+		api.GET("/dropboxes", dropBoxHandler.ListDropBoxes)
+		api.DELETE("/dropboxes/:id", middleware.RequireScope(services.ScopeDelete), dropBoxHandler.DeleteDropBox)
+	}
 
-		ext := strings.ToLower(filepath.Ext(fullPath))
+	// Drop box links authenticate through their token
+	drop := router.Group("/drop", groups.middleware(config.RouteGroupDrop)...)
+	drop.GET("/:token", dropBoxHandler.Info)
+	drop.POST("/:token", dropBoxHandler.Upload)
+}
 
-		// Raw param forces direct file serving (used by media player)
-		if c.Query("raw") == "1" {
-			c.File(fullPath)
-			return
-		}
+// setupTusRoutes sets the tus resumable upload routes
+func setupTusRoutes(router *gin.Engine, groups *routeGroups, tusHandler *handlers.TusHandler) {
+	tus := router.Group("/upload/tus", groups.middleware(config.RouteGroupUpload)...)
+	tus.OPTIONS("", tusHandler.Options)
+	tus.POST("", tusHandler.Create)
+	tus.OPTIONS("/:id", tusHandler.Options)
+	tus.HEAD("/:id", tusHandler.Head)
+	tus.PATCH("/:id", tusHandler.Patch)
+	tus.DELETE("/:id", tusHandler.Terminate)
+}
 
-		// Markdown viewer
-		if ext == ".md" {
-			c.File("./public/markdown-viewer/index.html")
-			return
+// cleanupTusUploads periodically removes abandoned resumable uploads
+func cleanupTusUploads(tusService *services.TusService, interval time.Duration, logger *logrus.Logger) {
+	for {
+		removed, err := tusService.Cleanup()
+		if err != nil {
+			logger.WithError(err).Warn("Failed to clean up resumable uploads")
+		} else if removed > 0 {
+			logger.Infof("Removed %d expired resumable uploads", removed)
 		}
+		time.Sleep(interval)
+	}
+}
 
-		// Media player (video / audio)
-		if isMediaExtension(ext) {
-			c.File("./public/video-player.html")
-			return
-		}
+// setupAuditRoutes sets the admin audit log routes
+func setupAuditRoutes(router *gin.Engine, groups *routeGroups, auditHandler *handlers.AuditHandler) {
+	admin := router.Group("/api/admin", groups.middleware(config.RouteGroupAPI)...)
+	admin.Use(middleware.RequireAdmin())
+	admin.GET("/audit", auditHandler.QueryAudit)
+}
 
-		// Directly serve other files
-		c.File(fullPath)
-	})
+// setupModerationRoutes sets the admin routes for reviewing incoming files
+func setupModerationRoutes(router *gin.Engine, groups *routeGroups, moderationHandler *handlers.ModerationHandler) {
+	admin := router.Group("/api/admin/incoming", groups.middleware(config.RouteGroupAPI)...)
+	admin.Use(middleware.RequireAdmin())
+	admin.GET("", moderationHandler.ListIncoming)
+	admin.POST("/approve", moderationHandler.Approve)
+	admin.POST("/bulk-approve", moderationHandler.BulkApprove)
+	admin.POST("/reject", moderationHandler.Reject)
+	admin.GET("/decisions", moderationHandler.ListDecisions)
+}
 
-	// File browser homepage
-	router.GET("/files", func(c *gin.Context) {
-		c.File("./public/file-browser.html")
-	})
+// setupProgressRoutes sets the upload progress routes
+func setupProgressRoutes(router *gin.Engine, groups *routeGroups, progressHandler *handlers.ProgressHandler) {
+	uploads := router.Group("/api/uploads", groups.middleware(config.RouteGroupAPI)...)
+	uploads.GET("", progressHandler.ListUploads)
+	uploads.GET("/events", progressHandler.StreamUploads)
+	uploads.GET("/:id", progressHandler.GetUpload)
 }
 
-// isMediaExtension checks if the extension is a supported audio/video type
-func isMediaExtension(ext string) bool {
-	switch ext {
-	// Video
-	case ".mp4", ".webm", ".ogv", ".mov", ".m4v", ".mkv", ".avi":
-		return true
-	// Audio
-	case ".mp3", ".wav", ".ogg", ".m4a", ".flac", ".aac":
-		return true
-	default:
-		return false
-	}
+// setupQuotaRoutes sets the quota usage routes
+func setupQuotaRoutes(router *gin.Engine, groups *routeGroups, quotaHandler *handlers.QuotaHandler) {
+	api := router.Group("/api", groups.middleware(config.RouteGroupAPI)...)
+	api.GET("/quota", quotaHandler.GetQuota)
+
+	admin := router.Group("/api/admin", groups.middleware(config.RouteGroupAPI)...)
+	admin.Use(middleware.RequireAdmin())
+	admin.GET("/quota", quotaHandler.ListQuotas)
 }
 
 // printStartupInfo prints startup information
-func printStartupInfo(cfg *config.Config, logger *logrus.Logger) {
+func printStartupInfo(cfg *config.Config, logger *logrus.Logger, certManager *services.CertificateManager, acmeManager *services.ACMEManager) {
 	// Get local IP
 	localIP := getLocalIP()
 
 	logger.Infof("=== Simple Server Go ===")
 	logger.Infof("Upload Directory: %s", cfg.Storage.UploadDir)
 	logger.Infof("Max Upload Size: %d MB", cfg.Storage.MaxUploadSize/(1024*1024))
+	if cfg.Auth.Enabled {
+		logger.Infof("Authentication: enabled for %v", cfg.Auth.RequireFor)
+	}
+	if cfg.Security.BasicAuth.Enabled {
+		logger.Infof("Basic authentication: enabled for %v", cfg.Security.BasicAuth.RequireFor)
+	}
+	if cfg.Security.ClientCerts.Enabled {
+		logger.Infof("Client certificates: accepted, required for %v", cfg.Security.ClientCerts.RequireFor)
+		if from := cfg.Security.ClientCerts.UsernameFrom; from != "" {
+			logger.Warnf("Client certificates: any certificate from %s logs in as the user named by its %s", cfg.Security.ClientCerts.CAFile, from)
+		}
+	}
+	if cfg.Audit.Enabled {
+		logger.Infof("Audit log: %s", cfg.Audit.File)
+	}
+	if cfg.Moderation.Enabled {
+		logger.Infof("Moderation: enabled, rejected files default to %s", cfg.Moderation.RejectAction)
+	}
+	if cfg.Quota.Enabled {
+		logger.Infof("Quotas: %d bytes per user, %d directory quotas, %d bytes kept free", cfg.Quota.PerUser, len(cfg.Quota.Dirs), cfg.Quota.MinFreeSpace)
+	}
+	if cfg.Scan.Enabled {
+		logger.Infof("Malware scanning: %s scanner, failing %s", cfg.Scan.Scanner, cfg.Scan.FailurePolicy)
+	}
+	if !cfg.Storage.ExposePrivateDir {
+		logger.Infof("Private files: only reachable through share links")
+	}
 
+	scheme := cfg.GetScheme()
 	if cfg.Server.Host == "0.0.0.0" {
-		logger.Infof("Server accessible at: http://%s:%d", localIP, cfg.Server.Port)
-		logger.Infof("Local access: http://localhost:%d", cfg.Server.Port)
+		logger.Infof("Server accessible at: %s://%s:%d", scheme, localIP, cfg.Server.Port)
+		logger.Infof("Local access: %s://localhost:%d", scheme, cfg.Server.Port)
 	} else {
-		logger.Infof("Server accessible at: http://%s:%d", cfg.Server.Host, cfg.Server.Port)
+		logger.Infof("Server accessible at: %s://%s:%d", scheme, cfg.Server.Host, cfg.Server.Port)
+	}
+
+	if certManager != nil {
+		if cert := certManager.Certificate(); cert != nil && cert.Leaf != nil {
+			logger.Infof("TLS certificate: %s (expires %s)", certManager.CertFile(), cert.Leaf.NotAfter.Format("2006-01-02"))
+		}
+		if certManager.SelfSigned() {
+			logger.Infof("Using a self-signed certificate; browsers will show a warning")
+		}
+	}
+
+	if acmeManager != nil {
+		logger.Infof("ACME directory: %s", cfg.Server.TLS.ACME.DirectoryURL)
+		for _, status := range acmeManager.Status(context.Background()) {
+			switch {
+			case !status.Issued:
+				logger.Infof("ACME certificate for %s: not issued yet, requested on first connection", status.Domain)
+			case time.Now().After(status.RenewAt):
+				logger.Infof("ACME certificate for %s: expires %s, renewal due", status.Domain, status.NotAfter.Format("2006-01-02"))
+			default:
+				logger.Infof("ACME certificate for %s: expires %s, renews after %s", status.Domain, status.NotAfter.Format("2006-01-02"), status.RenewAt.Format("2006-01-02"))
+			}
+		}
 	}
 }
 
@@ -332,3 +819,17 @@ func setupLogFile(cfg *config.Config, logger *logrus.Logger) {
 	// Print one-time info to console
 	logrus.Printf("Log output switched to file: %s", logFileName)
 }
+
+// hashPassword prints a bcrypt hash for use in the users file
+func hashPassword(args []string) {
+	if len(args) != 1 {
+		logrus.Fatalf("Usage: simple-server hash-password <password>")
+	}
+
+	hash, err := services.HashPassword(args[0])
+	if err != nil {
+		logrus.Fatalf("Failed to hash password: %v", err)
+	}
+
+	fmt.Println(hash)
+}
@@ -1,6 +1,7 @@
 function getUploadElements() {
   const uploadForm = document.getElementById('uploadForm');
   const fileInput = document.getElementById('fileInput');
+  const folderInput = document.getElementById('folderInput');
   const progressContainer = document.getElementById('progressContainer');
   const progressBar = document.getElementById('progressBar');
   const progressText = document.getElementById('progressText');
@@ -10,7 +11,7 @@ function getUploadElements() {
     return null;
   }
 
-  return { uploadForm, fileInput, progressContainer, progressBar, progressText };
+  return { uploadForm, fileInput, folderInput, progressContainer, progressBar, progressText };
 }
 
 function resetProgressBar(elements) {
@@ -38,13 +39,25 @@ function handleUpload(e) {
   const elements = getUploadElements();
   if (!elements) return;
 
-  if (!elements.fileInput.files || !elements.fileInput.files.length) {
+  let files = elements.fileInput.files;
+  if (elements.folderInput && elements.folderInput.files.length) {
+    files = elements.folderInput.files;
+  }
+  if (!files || !files.length) {
     alert('Please select a file to upload.');
     return;
   }
 
+  // Several files or a folder go to the batch endpoint, keeping relative paths
+  const batch = files.length > 1 || !!files[0].webkitRelativePath;
   const formData = new FormData();
-  formData.append('file', elements.fileInput.files[0]);
+  if (batch) {
+    for (const file of files) {
+      formData.append('files', file, file.webkitRelativePath || file.name);
+    }
+  } else {
+    formData.append('file', files[0]);
+  }
 
   const xhr = new XMLHttpRequest();
 
@@ -60,7 +73,19 @@ function handleUpload(e) {
 
   // Handle the completion of the request
   xhr.onload = () => {
-    if (xhr.status === 200) {
+    if (batch && (xhr.status === 200 || xhr.status === 207)) {
+      try {
+        const response = JSON.parse(xhr.responseText);
+        const failures = response.results.filter(r => r.error).map(r => `${r.path}: ${r.error}`);
+        let message = `${response.stored} file(s) uploaded`;
+        if (failures.length) {
+          message += `, ${failures.length} failed:\n` + failures.join('\n');
+        }
+        alert(message);
+      } catch (e) {
+        alert('Files uploaded.');
+      }
+    } else if (xhr.status === 200) {
       try {
         const response = JSON.parse(xhr.responseText);
         if (response.message) {
@@ -92,7 +117,7 @@ function handleUpload(e) {
     resetProgressBar(elements);
   };
 
-  xhr.open('POST', '/upload', true);
+  xhr.open('POST', batch ? '/upload/batch' : '/upload', true);
   xhr.send(formData);
 }
 
@@ -10,29 +10,166 @@ import (
 )
 
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Storage  StorageConfig  `mapstructure:"storage"`
-	Security SecurityConfig `mapstructure:"security"`
-	Logging  LoggingConfig  `mapstructure:"logging"`
+	Server     ServerConfig     `mapstructure:"server"`
+	Storage    StorageConfig    `mapstructure:"storage"`
+	Security   SecurityConfig   `mapstructure:"security"`
+	Logging    LoggingConfig    `mapstructure:"logging"`
+	Auth       AuthConfig       `mapstructure:"auth"`
+	Sharing    SharingConfig    `mapstructure:"sharing"`
+	DropBox    DropBoxConfig    `mapstructure:"dropBox"`
+	RateLimit  RateLimitConfig  `mapstructure:"rateLimit"`
+	Bandwidth  BandwidthConfig  `mapstructure:"bandwidth"`
+	CORS       CORSConfig       `mapstructure:"cors"`
+	Audit      AuditConfig      `mapstructure:"audit"`
+	Tus        TusConfig        `mapstructure:"tus"`
+	Moderation ModerationConfig `mapstructure:"moderation"`
+	Scan       ScanConfig       `mapstructure:"scan"`
+	Quota      QuotaConfig      `mapstructure:"quota"`
+	Progress   ProgressConfig   `mapstructure:"progress"`
 }
 
+// Route group names that can be referenced from config
+const (
+	RouteGroupAPI     = "api"
+	RouteGroupUpload  = "upload"
+	RouteGroupFiles   = "files"
+	RouteGroupPrivate = "private"
+	RouteGroupShare   = "share"
+	RouteGroupDrop    = "drop"
+)
+
 type ServerConfig struct {
-	Host         string        `mapstructure:"host"`
-	Port         int           `mapstructure:"port"`
-	ReadTimeout  time.Duration `mapstructure:"readTimeout"`
-	WriteTimeout time.Duration `mapstructure:"writeTimeout"`
+	Host            string        `mapstructure:"host"`
+	Port            int           `mapstructure:"port"`
+	ReadTimeout     time.Duration `mapstructure:"readTimeout"`
+	WriteTimeout    time.Duration `mapstructure:"writeTimeout"`
+	TrustedProxies  []string      `mapstructure:"trustedProxies"`
+	RemoteIPHeaders []string      `mapstructure:"remoteIPHeaders"`
+	TLS             TLSConfig     `mapstructure:"tls"`
+}
+
+type TLSConfig struct {
+	Enabled      bool       `mapstructure:"enabled"`
+	CertFile     string     `mapstructure:"certFile"`
+	KeyFile      string     `mapstructure:"keyFile"`
+	Hosts        []string   `mapstructure:"hosts"`
+	RedirectHTTP bool       `mapstructure:"redirectHTTP"`
+	HTTPPort     int        `mapstructure:"httpPort"`
+	ACME         ACMEConfig `mapstructure:"acme"`
+}
+
+// ACMEConfig configures automatic certificate provisioning. HTTP-01
+// challenges are answered on the HTTP listener, TLS-ALPN-01 on the TLS port.
+type ACMEConfig struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	AcceptTOS    bool          `mapstructure:"acceptTOS"`
+	Email        string        `mapstructure:"email"`
+	Domains      []string      `mapstructure:"domains"`
+	DirectoryURL string        `mapstructure:"directoryURL"`
+	CABundle     string        `mapstructure:"caBundle"` // trusted roots for the ACME server, e.g. Pebble
+	CacheDir     string        `mapstructure:"cacheDir"`
+	RenewBefore  time.Duration `mapstructure:"renewBefore"`
 }
 
 type StorageConfig struct {
-	UploadDir     string `mapstructure:"uploadDir"`
-	IncomingDir   string `mapstructure:"incomingDir"`
-	PrivateDir    string `mapstructure:"privateDir"`
-	MaxUploadSize int64  `mapstructure:"maxUploadSize"`
+	UploadDir        string          `mapstructure:"uploadDir"`
+	IncomingDir      string          `mapstructure:"incomingDir"`
+	PrivateDir       string          `mapstructure:"privateDir"`
+	MaxUploadSize    int64           `mapstructure:"maxUploadSize"`
+	DataDir          string          `mapstructure:"dataDir"`
+	QuarantineDir    string          `mapstructure:"quarantineDir"`
+	ExposePrivateDir bool            `mapstructure:"exposePrivateDir"`
+	Collision        CollisionConfig `mapstructure:"collision"`
+	// WritableDirs are the directories below uploadDir that uploads may
+	// target, in ACL path syntax; callers also need write permission
+	WritableDirs []string `mapstructure:"writableDirs"`
+}
+
+// Collision policies for uploads whose name already exists
+const (
+	CollisionReject    = "reject"
+	CollisionOverwrite = "overwrite"
+	CollisionRename    = "rename"
+)
+
+// Suffixes appended by the rename collision policy
+const (
+	SuffixNumeric   = "numeric"
+	SuffixTimestamp = "timestamp"
+)
+
+// CollisionConfig decides what happens when an upload's name is taken.
+// Rules override the default for directories below uploadDir; the most
+// specific matching rule wins.
+type CollisionConfig struct {
+	Policy string          `mapstructure:"policy"`
+	Suffix string          `mapstructure:"suffix"`
+	Rules  []CollisionRule `mapstructure:"rules"`
+}
+
+type CollisionRule struct {
+	Path   string `mapstructure:"path"`
+	Policy string `mapstructure:"policy"`
+	Suffix string `mapstructure:"suffix"`
 }
 
 type SecurityConfig struct {
-	AllowedExtensions []string `mapstructure:"allowedExtensions"`
-	BlockedPaths      []string `mapstructure:"blockedPaths"`
+	AllowedExtensions []string         `mapstructure:"allowedExtensions"`
+	BlockedPaths      []string         `mapstructure:"blockedPaths"`
+	ACL               []ACLRule        `mapstructure:"acl"`
+	ACLDefault        string           `mapstructure:"aclDefault"`
+	BasicAuth         BasicAuthConfig  `mapstructure:"basicAuth"`
+	ClientCerts       ClientCertConfig `mapstructure:"clientCerts"`
+	IPRules           []IPRule         `mapstructure:"ipRules"`
+
+	// AllowedMimeTypes restricts uploads by their detected content, e.g. "image/*"; empty allows all
+	AllowedMimeTypes []string `mapstructure:"allowedMimeTypes"`
+	// ContentMismatch decides what happens when the content does not match the extension
+	ContentMismatch string `mapstructure:"contentMismatch"`
+}
+
+// Policies for uploads whose content does not match their extension
+const (
+	MismatchReject     = "reject"
+	MismatchQuarantine = "quarantine"
+	MismatchAllow      = "allow"
+)
+
+type IPRule struct {
+	Routes []string `mapstructure:"routes"`
+	Allow  []string `mapstructure:"allow"`
+	Deny   []string `mapstructure:"deny"`
+}
+
+type BasicAuthConfig struct {
+	Enabled      bool     `mapstructure:"enabled"`
+	HtpasswdFile string   `mapstructure:"htpasswdFile"`
+	Realm        string   `mapstructure:"realm"`
+	RequireFor   []string `mapstructure:"requireFor"`
+}
+
+// ClientCertConfig configures mutual TLS. Verified client certificates are
+// mapped to user names; unmapped or missing certificates are anonymous.
+type ClientCertConfig struct {
+	Enabled      bool                `mapstructure:"enabled"`
+	CAFile       string              `mapstructure:"caFile"`
+	UsernameFrom string              `mapstructure:"usernameFrom"` // commonName, email, dnsName or empty (default) for mappings only
+	Mappings     []ClientCertMapping `mapstructure:"mappings"`
+	RequireFor   []string            `mapstructure:"requireFor"`
+}
+
+// ClientCertMapping maps a certificate subject or SAN to a user name
+type ClientCertMapping struct {
+	Subject  string `mapstructure:"subject"`
+	SAN      string `mapstructure:"san"`
+	Username string `mapstructure:"username"`
+}
+
+type ACLRule struct {
+	Path        string   `mapstructure:"path"`
+	Users       []string `mapstructure:"users"`
+	Groups      []string `mapstructure:"groups"`
+	Permissions []string `mapstructure:"permissions"`
 }
 
 type LoggingConfig struct {
@@ -43,6 +180,168 @@ type LoggingConfig struct {
 	LogDir  string `mapstructure:"logDir"`
 }
 
+type AuthConfig struct {
+	Enabled        bool          `mapstructure:"enabled"`
+	UsersFile      string        `mapstructure:"usersFile"`
+	Users          []UserConfig  `mapstructure:"users"`
+	SessionSecret  string        `mapstructure:"sessionSecret"`
+	SessionTTL     time.Duration `mapstructure:"sessionTTL"`
+	CookieName     string        `mapstructure:"cookieName"`
+	SecureCookie   bool          `mapstructure:"secureCookie"`
+	CookieSameSite string        `mapstructure:"cookieSameSite"`
+	RequireFor     []string      `mapstructure:"requireFor"`
+	// LoginLimit throttles login attempts per client, whether or not rateLimit is enabled
+	LoginLimit RateLimit `mapstructure:"loginLimit"`
+}
+
+type SharingConfig struct {
+	Enabled    bool          `mapstructure:"enabled"`
+	Secret     string        `mapstructure:"secret"`
+	DefaultTTL time.Duration `mapstructure:"defaultTTL"`
+	MaxTTL     time.Duration `mapstructure:"maxTTL"`
+}
+
+type DropBoxConfig struct {
+	Enabled    bool          `mapstructure:"enabled"`
+	DefaultTTL time.Duration `mapstructure:"defaultTTL"`
+}
+
+type RateLimitConfig struct {
+	Enabled                bool      `mapstructure:"enabled"`
+	API                    RateLimit `mapstructure:"api"`
+	Upload                 RateLimit `mapstructure:"upload"`
+	Download               RateLimit `mapstructure:"download"`
+	MaxConcurrentDownloads int       `mapstructure:"maxConcurrentDownloads"`
+}
+
+type RateLimit struct {
+	RequestsPerMinute float64 `mapstructure:"requestsPerMinute"`
+	Burst             int     `mapstructure:"burst"`
+}
+
+type BandwidthConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	Default       BandwidthTier `mapstructure:"default"`
+	Authenticated BandwidthTier `mapstructure:"authenticated"`
+}
+
+// BandwidthTier holds download ceilings in bytes per second; 0 means unlimited
+type BandwidthTier struct {
+	PerConnection int64 `mapstructure:"perConnection"`
+	Global        int64 `mapstructure:"global"`
+}
+
+type CORSConfig struct {
+	Policies []CORSPolicy `mapstructure:"policies"`
+}
+
+type CORSPolicy struct {
+	Routes           []string      `mapstructure:"routes"`
+	AllowedOrigins   []string      `mapstructure:"allowedOrigins"`
+	AllowedMethods   []string      `mapstructure:"allowedMethods"`
+	AllowedHeaders   []string      `mapstructure:"allowedHeaders"`
+	AllowCredentials bool          `mapstructure:"allowCredentials"`
+	MaxAge           time.Duration `mapstructure:"maxAge"`
+}
+
+// AuditConfig configures the audit log of file operations. It is written
+// separately from the access log and rotated by size.
+type AuditConfig struct {
+	Enabled    bool   `mapstructure:"enabled"`
+	File       string `mapstructure:"file"`
+	MaxSizeMB  int    `mapstructure:"maxSizeMB"`
+	MaxBackups int    `mapstructure:"maxBackups"`
+}
+
+// ModerationConfig configures the admin review of files in the incoming directory
+type ModerationConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// RejectAction is what happens to rejected files by default: delete or quarantine
+	RejectAction string `mapstructure:"rejectAction"`
+	// HistoryLimit caps the number of recorded decisions kept
+	HistoryLimit int `mapstructure:"historyLimit"`
+}
+
+// Ways to dispose of a rejected file
+const (
+	RejectDelete     = "delete"
+	RejectQuarantine = "quarantine"
+)
+
+// ScanConfig configures malware scanning of completed uploads
+type ScanConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Scanner is clamd or command
+	Scanner string `mapstructure:"scanner"`
+	// Address of the clamd daemon, tcp://host:port or unix:///path/to/socket
+	Address string `mapstructure:"address"`
+	// Command runs an external scanner; the file path replaces a "{file}"
+	// argument or is appended. Exit code 0 means clean and 1 infected.
+	Command []string      `mapstructure:"command"`
+	Timeout time.Duration `mapstructure:"timeout"`
+	// FailurePolicy decides what happens to an upload that could not be scanned
+	FailurePolicy string `mapstructure:"failurePolicy"`
+}
+
+// Scanner implementations
+const (
+	ScannerClamd   = "clamd"
+	ScannerCommand = "command"
+)
+
+// Scan failure policies: fail open stores unscanned uploads, fail closed refuses them
+const (
+	ScanFailOpen   = "open"
+	ScanFailClosed = "closed"
+)
+
+// QuotaConfig limits the bytes stored per user and per directory. Limits of
+// zero are unlimited.
+type QuotaConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// PerUser is the quota of each user without an entry in Users
+	PerUser int64       `mapstructure:"perUser"`
+	Users   []UserQuota `mapstructure:"users"`
+	// Dirs limit the bytes below directories of the upload directory, whoever stored them
+	Dirs []DirQuota `mapstructure:"dirs"`
+	// MinFreeSpace refuses uploads that would leave less free disk space, in bytes
+	MinFreeSpace int64 `mapstructure:"minFreeSpace"`
+}
+
+type UserQuota struct {
+	Username string `mapstructure:"username"`
+	Bytes    int64  `mapstructure:"bytes"`
+}
+
+type DirQuota struct {
+	// Path is relative to the upload directory
+	Path  string `mapstructure:"path"`
+	Bytes int64  `mapstructure:"bytes"`
+}
+
+// ProgressConfig configures the server-side progress of uploads
+type ProgressConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Interval is the minimum time between progress events of one upload
+	Interval time.Duration `mapstructure:"interval"`
+	// Retention is how long finished uploads can still be queried
+	Retention time.Duration `mapstructure:"retention"`
+}
+
+// TusConfig configures resumable uploads with the tus protocol
+type TusConfig struct {
+	Enabled         bool          `mapstructure:"enabled"`
+	Expiration      time.Duration `mapstructure:"expiration"`
+	CleanupInterval time.Duration `mapstructure:"cleanupInterval"`
+}
+
+type UserConfig struct {
+	Username     string   `mapstructure:"username"`
+	PasswordHash string   `mapstructure:"passwordHash"`
+	Groups       []string `mapstructure:"groups"`
+	Admin        bool     `mapstructure:"admin"`
+}
+
 // LoadConfig loads the configuration file and environment variables
 func LoadConfig() (*Config, error) {
 	config := &Config{}
@@ -92,6 +391,10 @@ func LoadConfig() (*Config, error) {
 		setDefaultValues()
 	}
 
+	// Settings added after the original config format get defaults either way,
+	// so existing config files keep working
+	setOptionalDefaultValues()
+
 	// Environment variable mapping (highest priority)
 	if host := os.Getenv("HOST"); host != "" {
 		viper.Set("server.host", host)
@@ -121,7 +424,9 @@ func setDefaultValues() {
 	viper.SetDefault("storage.maxUploadSize", 1000*1024*1024) // 1000MB
 
 	viper.SetDefault("security.allowedExtensions", []string{".jpg", ".png", ".pdf", ".md", ".txt", ".html", ".css", ".js"})
+	viper.SetDefault("storage.writableDirs", []string{})
 	viper.SetDefault("security.blockedPaths", []string{"incoming", "private-files"})
+	viper.SetDefault("security.contentMismatch", MismatchReject)
 
 	viper.SetDefault("logging.level", "info")
 	viper.SetDefault("logging.format", "json")
@@ -130,6 +435,97 @@ func setDefaultValues() {
 	viper.SetDefault("logging.logDir", "./logs")
 }
 
+// setOptionalDefaultValues sets defaults for optional config sections
+func setOptionalDefaultValues() {
+	viper.SetDefault("server.remoteIPHeaders", []string{"X-Forwarded-For", "X-Real-IP"})
+	viper.SetDefault("server.tls.enabled", false)
+	viper.SetDefault("server.tls.redirectHTTP", false)
+	viper.SetDefault("server.tls.httpPort", 8080)
+	viper.SetDefault("server.tls.acme.enabled", false)
+	viper.SetDefault("server.tls.acme.directoryURL", "https://acme-v02.api.letsencrypt.org/directory")
+	viper.SetDefault("server.tls.acme.cacheDir", "./data/acme")
+	viper.SetDefault("server.tls.acme.renewBefore", "720h")
+
+	viper.SetDefault("storage.dataDir", "./data")
+	viper.SetDefault("storage.quarantineDir", "./data/quarantine")
+	viper.SetDefault("storage.exposePrivateDir", true)
+	viper.SetDefault("storage.collision.policy", CollisionRename)
+	viper.SetDefault("storage.collision.suffix", SuffixNumeric)
+
+	viper.SetDefault("security.aclDefault", "allow")
+	viper.SetDefault("security.basicAuth.enabled", false)
+	viper.SetDefault("security.basicAuth.htpasswdFile", "./data/htpasswd")
+	viper.SetDefault("security.basicAuth.realm", "StreamFile Server")
+	viper.SetDefault("security.basicAuth.requireFor", []string{RouteGroupUpload, RouteGroupPrivate})
+	viper.SetDefault("security.clientCerts.enabled", false)
+	viper.SetDefault("security.clientCerts.usernameFrom", "")
+	viper.SetDefault("security.clientCerts.requireFor", []string{})
+
+	viper.SetDefault("auth.enabled", false)
+	viper.SetDefault("auth.usersFile", "./data/users.json")
+	viper.SetDefault("auth.sessionTTL", "24h")
+	viper.SetDefault("auth.cookieName", "ssg_session")
+	viper.SetDefault("auth.cookieSameSite", "lax")
+	viper.SetDefault("auth.requireFor", []string{RouteGroupAPI, RouteGroupUpload, RouteGroupFiles})
+	viper.SetDefault("auth.loginLimit.requestsPerMinute", 10)
+	viper.SetDefault("auth.loginLimit.burst", 5)
+
+	viper.SetDefault("sharing.enabled", true)
+	viper.SetDefault("sharing.defaultTTL", "168h")
+	viper.SetDefault("sharing.maxTTL", "720h")
+
+	viper.SetDefault("dropBox.enabled", true)
+	viper.SetDefault("dropBox.defaultTTL", "168h")
+
+	viper.SetDefault("rateLimit.enabled", false)
+	viper.SetDefault("rateLimit.api.requestsPerMinute", 120)
+	viper.SetDefault("rateLimit.api.burst", 30)
+	viper.SetDefault("rateLimit.upload.requestsPerMinute", 30)
+	viper.SetDefault("rateLimit.upload.burst", 10)
+	viper.SetDefault("rateLimit.download.requestsPerMinute", 600)
+	viper.SetDefault("rateLimit.download.burst", 100)
+	viper.SetDefault("rateLimit.maxConcurrentDownloads", 4)
+
+	viper.SetDefault("bandwidth.enabled", false)
+
+	viper.SetDefault("audit.enabled", false)
+	viper.SetDefault("audit.file", "./logs/audit.log")
+	viper.SetDefault("audit.maxSizeMB", 10)
+	viper.SetDefault("audit.maxBackups", 5)
+
+	viper.SetDefault("tus.enabled", true)
+	viper.SetDefault("tus.expiration", "24h")
+	viper.SetDefault("tus.cleanupInterval", "1h")
+
+	viper.SetDefault("moderation.enabled", false)
+	viper.SetDefault("moderation.rejectAction", RejectQuarantine)
+	viper.SetDefault("moderation.historyLimit", 1000)
+
+	viper.SetDefault("scan.enabled", false)
+	viper.SetDefault("scan.scanner", ScannerClamd)
+	viper.SetDefault("scan.address", "tcp://127.0.0.1:3310")
+	viper.SetDefault("scan.timeout", "2m")
+	viper.SetDefault("scan.failurePolicy", ScanFailClosed)
+
+	viper.SetDefault("quota.enabled", false)
+	viper.SetDefault("quota.perUser", 0)
+	viper.SetDefault("quota.minFreeSpace", 0)
+
+	viper.SetDefault("progress.enabled", true)
+	viper.SetDefault("progress.interval", "1s")
+	viper.SetDefault("progress.retention", "10m")
+}
+
+// HasRouteGroup reports whether a route group name is listed
+func HasRouteGroup(groups []string, group string) bool {
+	for _, g := range groups {
+		if g == group || g == "*" {
+			return true
+		}
+	}
+	return false
+}
+
 // copyConfigFile copies a config file
 func copyConfigFile(src, dst string) error {
 	srcFile, err := os.Open(src)
@@ -158,6 +554,19 @@ func (c *Config) GetListenAddr() string {
 	return fmt.Sprintf("%s:%d", c.Server.Host, c.Server.Port)
 }
 
+// GetRedirectListenAddr gets the listen address of the HTTP to HTTPS redirect
+func (c *Config) GetRedirectListenAddr() string {
+	return fmt.Sprintf("%s:%d", c.Server.Host, c.Server.TLS.HTTPPort)
+}
+
+// GetScheme gets the URL scheme the server is reachable with
+func (c *Config) GetScheme() string {
+	if c.Server.TLS.Enabled {
+		return "https"
+	}
+	return "http"
+}
+
 // PrintConfig prints current config information
 func (c *Config) PrintConfig() {
 	log.Println("=== Current Config Information ===")
@@ -173,4 +582,5 @@ func (c *Config) PrintConfig() {
 	}
 	log.Printf("Allowed extensions: %v", c.Security.AllowedExtensions)
 	log.Printf("Blocked paths: %v", c.Security.BlockedPaths)
+	log.Printf("Authentication enabled: %v", c.Auth.Enabled)
 }
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+	"simple-server/src/backend/services"
+	"simple-server/src/backend/utils"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultAuditLimit caps audit queries that do not set a limit
+const defaultAuditLimit = 1000
+
+type AuditHandler struct {
+	auditService *services.AuditService
+	logger       *logrus.Logger
+}
+
+func NewAuditHandler(auditService *services.AuditService, logger *logrus.Logger) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+		logger:       logger,
+	}
+}
+
+// QueryAudit returns audit events filtered by user, path prefix, action and
+// time range. Times are RFC 3339. Events are sorted oldest first and a limit
+// keeps the most recent ones.
+func (h *AuditHandler) QueryAudit(c *gin.Context) {
+	filter := services.AuditFilter{
+		User:       c.Query("user"),
+		PathPrefix: c.Query("path"),
+		Action:     c.Query("action"),
+		Limit:      defaultAuditLimit,
+	}
+
+	var err error
+	if since := c.Query("since"); since != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			utils.SendError(c, http.StatusBadRequest, "Invalid since", "use RFC 3339, e.g. 2024-01-02T15:04:05Z")
+			return
+		}
+	}
+	if until := c.Query("until"); until != "" {
+		if filter.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			utils.SendError(c, http.StatusBadRequest, "Invalid until", "use RFC 3339, e.g. 2024-01-02T15:04:05Z")
+			return
+		}
+	}
+	if limit := c.Query("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit <= 0 {
+			utils.SendError(c, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+	}
+
+	events, err := h.auditService.Query(filter)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to read audit log")
+		utils.SendError(c, http.StatusInternalServerError, "Failed to read audit log")
+		return
+	}
+
+	utils.SendJSON(c, http.StatusOK, gin.H{
+		"events": events,
+		"count":  len(events),
+	})
+}
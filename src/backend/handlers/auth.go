@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"simple-server/src/backend/config"
+	"simple-server/src/backend/services"
+	"simple-server/src/backend/utils"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type AuthHandler struct {
+	config   *config.Config
+	users    *services.UserService
+	sessions *services.SessionService
+	logger   *logrus.Logger
+}
+
+type loginRequest struct {
+	Username string `json:"username" form:"username"`
+	Password string `json:"password" form:"password"`
+}
+
+func NewAuthHandler(cfg *config.Config, users *services.UserService, sessions *services.SessionService, logger *logrus.Logger) *AuthHandler {
+	return &AuthHandler{
+		config:   cfg,
+		users:    users,
+		sessions: sessions,
+		logger:   logger,
+	}
+}
+
+// Login checks credentials and sets the session cookie
+func (h *AuthHandler) Login(c *gin.Context) {
+	var req loginRequest
+	if err := c.ShouldBind(&req); err != nil || req.Username == "" || req.Password == "" {
+		utils.SendError(c, http.StatusBadRequest, "Username and password are required")
+		return
+	}
+
+	user, err := h.users.Authenticate(req.Username, req.Password)
+	if err != nil {
+		h.logger.WithFields(logrus.Fields{
+			"user":      req.Username,
+			"client_ip": c.ClientIP(),
+		}).Warn("Failed login attempt")
+		utils.SendError(c, http.StatusUnauthorized, "Invalid username or password")
+		return
+	}
+
+	value, _, err := h.sessions.Create(user.Username)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to create session")
+		utils.SendError(c, http.StatusInternalServerError, "Login failed")
+		return
+	}
+	c.SetSameSite(h.sameSite())
+	c.SetCookie(h.config.Auth.CookieName, value, int(h.sessions.TTL().Seconds()), "/", "", h.config.Auth.SecureCookie, true)
+
+	h.logger.WithFields(logrus.Fields{
+		"user":      user.Username,
+		"client_ip": c.ClientIP(),
+	}).Info("User logged in")
+
+	utils.SendSuccess(c, "Logged in", user.Identity("session"))
+}
+
+// Logout revokes the session and clears its cookie
+func (h *AuthHandler) Logout(c *gin.Context) {
+	if value, err := c.Cookie(h.config.Auth.CookieName); err == nil && value != "" {
+		if err := h.sessions.Revoke(value); err != nil && !errors.Is(err, services.ErrInvalidSession) {
+			h.logger.WithError(err).Error("Failed to revoke session")
+			utils.SendError(c, http.StatusInternalServerError, "Logout failed")
+			return
+		}
+	}
+
+	c.SetSameSite(h.sameSite())
+	c.SetCookie(h.config.Auth.CookieName, "", -1, "/", "", h.config.Auth.SecureCookie, true)
+
+	utils.SendSuccess(c, "Logged out", nil)
+}
+
+// CurrentUser returns the identity of the caller
+func (h *AuthHandler) CurrentUser(c *gin.Context) {
+	identity := utils.GetIdentity(c)
+	if identity == nil {
+		utils.SendError(c, http.StatusUnauthorized, "Not logged in")
+		return
+	}
+
+	utils.SendJSON(c, http.StatusOK, identity)
+}
+
+// sameSite returns the configured SameSite mode of the session cookie.
+// Cross-site dashboards calling the API with credentials need "none".
+func (h *AuthHandler) sameSite() http.SameSite {
+	switch strings.ToLower(h.config.Auth.CookieSameSite) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
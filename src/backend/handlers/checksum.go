@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"simple-server/src/backend/config"
+	"simple-server/src/backend/services"
+	"simple-server/src/backend/utils"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ChecksumHandler serves the digests of files under the upload directory
+type ChecksumHandler struct {
+	config          *config.Config
+	fileService     *services.FileService
+	checksumService *services.ChecksumService
+	logger          *logrus.Logger
+}
+
+func NewChecksumHandler(cfg *config.Config, fileService *services.FileService, checksumService *services.ChecksumService, logger *logrus.Logger) *ChecksumHandler {
+	return &ChecksumHandler{
+		config:          cfg,
+		fileService:     fileService,
+		checksumService: checksumService,
+		logger:          logger,
+	}
+}
+
+// GetChecksum returns the SHA-256 of a readable file. Files stored before
+// digests were kept are hashed on first request, a few at a time.
+func (h *ChecksumHandler) GetChecksum(c *gin.Context) {
+	filePath := c.Query("path")
+	if filePath == "" {
+		utils.SendError(c, http.StatusBadRequest, "Path parameter is required")
+		return
+	}
+
+	cleanPath := utils.SanitizePath(filepath.FromSlash(filePath))
+	fullPath := filepath.Join(h.config.Storage.UploadDir, cleanPath)
+	if cleanPath == "" {
+		utils.SendError(c, http.StatusForbidden, "Access denied")
+		return
+	}
+	if !h.fileService.IsPublicPath(cleanPath) {
+		utils.SendError(c, http.StatusNotFound, "File not found")
+		return
+	}
+	if !h.fileService.CanAccess(utils.GetIdentity(c), filepath.ToSlash(cleanPath), services.PermRead) {
+		utils.SendError(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	info, err := os.Stat(fullPath)
+	if err != nil || info.IsDir() {
+		utils.SendError(c, http.StatusNotFound, "File not found")
+		return
+	}
+
+	sum, err := h.checksumService.Sum(fullPath)
+	if errors.Is(err, services.ErrChecksumBusy) {
+		c.Header("Retry-After", "5")
+		utils.SendError(c, http.StatusServiceUnavailable, "Checksum is being computed for other files", "try again shortly")
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).WithField("path", cleanPath).Error("Failed to hash file")
+		utils.SendError(c, http.StatusInternalServerError, "Failed to compute checksum")
+		return
+	}
+
+	utils.SendJSON(c, http.StatusOK, gin.H{
+		"path":   "/" + filepath.ToSlash(cleanPath),
+		"size":   info.Size(),
+		"sha256": sum,
+		"digest": sha256Digest(sum),
+	})
+}
+
+// setDigestHeaders advertises the stored digest of a file about to be served.
+// Files without a current digest are served without, rather than hashed on the spot.
+func setDigestHeaders(c *gin.Context, checksumService *services.ChecksumService, fullPath string) {
+	if checksumService == nil {
+		return
+	}
+	sum, ok := checksumService.Lookup(fullPath)
+	if !ok {
+		return
+	}
+
+	c.Header("Digest", sha256Digest(sum))
+	c.Header("ETag", `"`+sum+`"`)
+}
+
+// sha256Digest formats a hex SHA-256 as a Digest header value
+func sha256Digest(sum string) string {
+	raw, _ := hex.DecodeString(sum)
+	return "sha-256=" + base64.StdEncoding.EncodeToString(raw)
+}
+
+// expectedChecksums reads the digests a client expects an upload to have
+// from a hex sha256 parameter and the Content-MD5 and Digest headers
+func expectedChecksums(header textproto.MIMEHeader, sha256Hex string) (services.Checksums, error) {
+	var expected services.Checksums
+
+	if sha256Hex != "" {
+		sum, err := hex.DecodeString(sha256Hex)
+		if err != nil || len(sum) != 32 {
+			return expected, errors.New("sha256 must be 64 hex digits")
+		}
+		expected.SHA256 = sum
+	}
+
+	if value := header.Get("Content-MD5"); value != "" {
+		sum, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(sum) != 16 {
+			return expected, errors.New("invalid Content-MD5 header")
+		}
+		expected.MD5 = sum
+	}
+
+	// Digest: sha-256=<base64>, md5=<base64>; other algorithms are ignored
+	for _, item := range strings.Split(header.Get("Digest"), ",") {
+		algorithm, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok {
+			continue
+		}
+
+		var size int
+		var target *[]byte
+		switch strings.ToLower(algorithm) {
+		case "sha-256":
+			size, target = 32, &expected.SHA256
+		case "md5":
+			size, target = 16, &expected.MD5
+		default:
+			continue
+		}
+
+		sum, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(sum) != size {
+			return expected, errors.New("invalid " + algorithm + " in Digest header")
+		}
+		if *target != nil && string(*target) != string(sum) {
+			return expected, errors.New("conflicting " + algorithm + " digests")
+		}
+		*target = sum
+	}
+
+	return expected, nil
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"simple-server/src/backend/config"
+	"simple-server/src/backend/services"
+	"simple-server/src/backend/utils"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DownloadHandler struct {
+	config          *config.Config
+	fileService     *services.FileService
+	checksumService *services.ChecksumService
+}
+
+func NewDownloadHandler(cfg *config.Config, fileService *services.FileService, checksumService *services.ChecksumService) *DownloadHandler {
+	return &DownloadHandler{
+		config:          cfg,
+		fileService:     fileService,
+		checksumService: checksumService,
+	}
+}
+
+// ServeFile handles file browsing and download under the upload directory
+func (h *DownloadHandler) ServeFile(c *gin.Context) {
+	filePath := c.Param("filepath")
+
+	// Use path handling function from utils package
+	cleanPath := utils.SanitizePath(filePath)
+	fullPath := filepath.Join(h.config.Storage.UploadDir, cleanPath)
+
+	// Get absolute path for security check
+	absUploadDir, err := filepath.Abs(h.config.Storage.UploadDir)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	absFullPath, err := filepath.Abs(fullPath)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	// Security check: ensure path is within upload directory
+	if !strings.HasPrefix(absFullPath+string(filepath.Separator), absUploadDir+string(filepath.Separator)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	// The incoming and private directories and blocked paths have their own routes
+	if !h.fileService.IsPublicPath(cleanPath) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+
+	// Check if file/directory exists
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+
+	identity := utils.GetIdentity(c)
+
+	if info.IsDir() {
+		if !h.fileService.CanAccess(identity, cleanPath, services.PermList) && !h.fileService.CanAccess(identity, cleanPath, services.PermRead) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+
+		// Check if index.html exists
+		indexPath := filepath.Join(fullPath, "index.html")
+		if _, err := os.Stat(indexPath); err == nil {
+			c.File(indexPath)
+			return
+		}
+		// Otherwise return file browser
+		c.File("./public/file-browser.html")
+		return
+	}
+
+	if !h.fileService.CanAccess(identity, cleanPath, services.PermRead) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	ext := strings.ToLower(filepath.Ext(fullPath))
+
+	// Raw param forces direct file serving (used by media player)
+	if c.Query("raw") == "1" {
+		setDigestHeaders(c, h.checksumService, fullPath)
+		c.File(fullPath)
+		return
+	}
+
+	// Markdown viewer
+	if ext == ".md" {
+		c.File("./public/markdown-viewer/index.html")
+		return
+	}
+
+	// Media player (video / audio)
+	if isMediaExtension(ext) {
+		c.File("./public/video-player.html")
+		return
+	}
+
+	// Directly serve other files
+	setDigestHeaders(c, h.checksumService, fullPath)
+	c.File(fullPath)
+}
+
+// ServeFileBrowser serves the file browser homepage
+func (h *DownloadHandler) ServeFileBrowser(c *gin.Context) {
+	c.File("./public/file-browser.html")
+}
+
+// ServePrivateFile handles direct access to files in the private directory
+func (h *DownloadHandler) ServePrivateFile(c *gin.Context) {
+	cleanPath := utils.SanitizePath(c.Param("filepath"))
+	utils.Audit(c).Path = utils.AuditPath(h.config.Storage.UploadDir, filepath.Join(h.config.Storage.PrivateDir, cleanPath))
+	if !utils.IsValidPath(h.config.Storage.PrivateDir, cleanPath) {
+		utils.SendError(c, http.StatusForbidden, "Access denied")
+		return
+	}
+	fullPath := filepath.Join(h.config.Storage.PrivateDir, cleanPath)
+
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		utils.SendError(c, http.StatusNotFound, "File not found")
+		return
+	}
+
+	// Directories are never listed, only their index page is served
+	if info.IsDir() {
+		fullPath = filepath.Join(fullPath, "index.html")
+		if _, err := os.Stat(fullPath); err != nil {
+			utils.SendError(c, http.StatusNotFound, "File not found")
+			return
+		}
+	}
+
+	if !h.fileService.CanAccessPath(utils.GetIdentity(c), fullPath, services.PermRead) {
+		utils.SendError(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	setDigestHeaders(c, h.checksumService, fullPath)
+	c.File(fullPath)
+}
+
+// isMediaExtension checks if the extension is a supported audio/video type
+func isMediaExtension(ext string) bool {
+	switch ext {
+	// Video
+	case ".mp4", ".webm", ".ogv", ".mov", ".m4v", ".mkv", ".avi":
+		return true
+	// Audio
+	case ".mp3", ".wav", ".ogg", ".m4a", ".flac", ".aac":
+		return true
+	default:
+		return false
+	}
+}
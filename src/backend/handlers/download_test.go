@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"simple-server/src/backend/config"
+	"simple-server/src/backend/services"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+func TestPublicRoutesHidePrivateFiles(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	uploadDir := t.TempDir()
+	cfg := &config.Config{
+		Storage: config.StorageConfig{
+			UploadDir:   uploadDir,
+			IncomingDir: filepath.Join(uploadDir, "incoming"),
+			PrivateDir:  filepath.Join(uploadDir, "private-files"),
+		},
+		Security: config.SecurityConfig{
+			BlockedPaths: []string{"internal"},
+			ACLDefault:   "allow",
+		},
+	}
+	for _, name := range []string{"public.txt", "private-files/secret.txt", "incoming/new.txt", "internal/notes.txt"} {
+		path := filepath.Join(uploadDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fileService := services.NewFileService(cfg)
+	router := gin.New()
+	router.GET("/files/*filepath", NewDownloadHandler(cfg, fileService, nil).ServeFile)
+	router.GET("/api/checksum", NewChecksumHandler(cfg, fileService, nil, logrus.New()).GetChecksum)
+
+	tests := []struct {
+		url  string
+		want int
+	}{
+		{url: "/files/public.txt", want: http.StatusOK},
+		{url: "/files/private-files/secret.txt", want: http.StatusNotFound},
+		{url: "/files/private-files/", want: http.StatusNotFound},
+		{url: "/files/./private-files/../private-files/secret.txt", want: http.StatusNotFound},
+		{url: "/files/incoming/new.txt", want: http.StatusNotFound},
+		{url: "/files/internal/notes.txt", want: http.StatusNotFound},
+		{url: "/api/checksum?path=private-files/secret.txt", want: http.StatusNotFound},
+		{url: "/api/checksum?path=incoming/new.txt", want: http.StatusNotFound},
+		{url: "/api/checksum?path=internal/notes.txt", want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+		if w.Code != tt.want {
+			t.Errorf("GET %s = %d, want %d", tt.url, w.Code, tt.want)
+		}
+	}
+}
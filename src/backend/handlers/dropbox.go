@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"simple-server/src/backend/config"
+	"simple-server/src/backend/services"
+	"simple-server/src/backend/utils"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// dropBoxFormOverhead is the room left in a drop box upload's body for the
+// multipart boundaries, part headers and form fields around the file
+const dropBoxFormOverhead = 64 * 1024
+
+type DropBoxHandler struct {
+	config         *config.Config
+	dropBoxService *services.DropBoxService
+	uploadService  *services.UploadService
+	logger         *logrus.Logger
+}
+
+type createDropBoxRequest struct {
+	Label             string   `json:"label"`
+	Folder            string   `json:"folder"`
+	MaxBytes          int64    `json:"maxBytes"`
+	MaxFiles          int      `json:"maxFiles"`
+	AllowedExtensions []string `json:"allowedExtensions"`
+	TTL               string   `json:"ttl"`
+}
+
+func NewDropBoxHandler(cfg *config.Config, dropBoxService *services.DropBoxService, uploadService *services.UploadService, logger *logrus.Logger) *DropBoxHandler {
+	return &DropBoxHandler{
+		config:         cfg,
+		dropBoxService: dropBoxService,
+		uploadService:  uploadService,
+		logger:         logger,
+	}
+}
+
+// CreateDropBox creates an upload-only link
+func (h *DropBoxHandler) CreateDropBox(c *gin.Context) {
+	identity := utils.GetIdentity(c)
+	if identity == nil {
+		utils.SendError(c, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	var req createDropBoxRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.SendError(c, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	var ttl time.Duration
+	if req.TTL != "" {
+		var err error
+		ttl, err = time.ParseDuration(req.TTL)
+		if err != nil || ttl <= 0 {
+			utils.SendError(c, http.StatusBadRequest, "Invalid ttl", "use a duration such as 168h")
+			return
+		}
+	}
+
+	box, token, err := h.dropBoxService.Create(services.DropBoxRequest{
+		Label:             req.Label,
+		Folder:            req.Folder,
+		MaxBytes:          req.MaxBytes,
+		MaxFiles:          req.MaxFiles,
+		AllowedExtensions: req.AllowedExtensions,
+		TTL:               ttl,
+	}, identity.Username)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to create drop box")
+		utils.SendError(c, http.StatusInternalServerError, "Failed to create drop box")
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"dropbox_id": box.ID,
+		"label":      box.Label,
+		"folder":     box.Folder,
+		"user":       identity.Username,
+	}).Info("Drop box created")
+
+	utils.SendSuccess(c, "Drop box created", gin.H{
+		"url":     utils.RequestBaseURL(c) + "/drop/" + token,
+		"dropBox": box,
+	})
+}
+
+// ListDropBoxes lists the caller's drop boxes, or all of them for admins
+func (h *DropBoxHandler) ListDropBoxes(c *gin.Context) {
+	identity := utils.GetIdentity(c)
+	if identity == nil {
+		utils.SendError(c, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	owner := identity.Username
+	if identity.Admin {
+		owner = ""
+	}
+
+	utils.SendJSON(c, http.StatusOK, gin.H{"dropBoxes": h.dropBoxService.List(owner)})
+}
+
+// DeleteDropBox disables a drop box link
+func (h *DropBoxHandler) DeleteDropBox(c *gin.Context) {
+	identity := utils.GetIdentity(c)
+	if identity == nil {
+		utils.SendError(c, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	owner := identity.Username
+	if identity.Admin {
+		owner = ""
+	}
+
+	if err := h.dropBoxService.Delete(c.Param("id"), owner); err != nil {
+		if errors.Is(err, services.ErrDropBoxNotFound) {
+			utils.SendError(c, http.StatusNotFound, "Drop box not found")
+			return
+		}
+		h.logger.WithError(err).Error("Failed to delete drop box")
+		utils.SendError(c, http.StatusInternalServerError, "Failed to delete drop box")
+		return
+	}
+
+	utils.SendSuccess(c, "Drop box deleted", nil)
+}
+
+// Info describes a drop box to its users, or serves the upload page to browsers
+func (h *DropBoxHandler) Info(c *gin.Context) {
+	box, ok := h.resolve(c)
+	if !ok {
+		return
+	}
+
+	if strings.Contains(c.GetHeader("Accept"), "text/html") {
+		c.File("./public/dropbox.html")
+		return
+	}
+
+	info := gin.H{
+		"label":             box.Label,
+		"expiresAt":         box.ExpiresAt,
+		"allowedExtensions": box.AllowedExtensions,
+	}
+	if box.MaxBytes > 0 {
+		info["remainingBytes"] = box.MaxBytes - box.UsedBytes
+	}
+	if box.MaxFiles > 0 {
+		info["remainingFiles"] = box.MaxFiles - box.UsedFiles
+	}
+	if box.AllowedExtensions == nil {
+		info["allowedExtensions"] = h.config.Security.AllowedExtensions
+	}
+
+	utils.SendJSON(c, http.StatusOK, info)
+}
+
+// Upload stores a file sent through a drop box link
+func (h *DropBoxHandler) Upload(c *gin.Context) {
+	box, ok := h.resolve(c)
+	if !ok {
+		return
+	}
+
+	// Parsing the form spools the file to disk, so cap the body at what the
+	// box can still take before reading it
+	limit := h.config.Storage.MaxUploadSize
+	if box.MaxBytes > 0 {
+		remaining := box.MaxBytes - box.UsedBytes
+		if remaining <= 0 {
+			utils.SendError(c, http.StatusRequestEntityTooLarge, "Drop box limit reached")
+			return
+		}
+		if remaining < limit {
+			limit = remaining
+		}
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit+dropBoxFormOverhead)
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			sendUploadError(c, h.logger, err)
+			return
+		}
+		utils.SendError(c, http.StatusBadRequest, "No file uploaded", err.Error())
+		return
+	}
+	defer file.Close()
+
+	utils.Audit(c).Detail = "drop box " + box.ID + ": " + header.Filename
+
+	expected, err := expectedChecksums(header.Header, c.PostForm("sha256"))
+	if err != nil {
+		utils.SendError(c, http.StatusBadRequest, "Invalid checksum", err.Error())
+		return
+	}
+
+	req := services.UploadRequest{
+		Filename:          header.Filename,
+		Dir:               h.dropBoxService.Dir(box),
+		Size:              header.Size,
+		AllowedExtensions: box.AllowedExtensions,
+		Uploader:          services.DropBoxUploader(box.ID),
+		ClientIP:          c.ClientIP(),
+		Expected:          expected,
+	}
+	if err := h.uploadService.Validate(req); err != nil {
+		sendUploadError(c, h.logger, err)
+		return
+	}
+
+	// The multipart form is already received, so header.Size counts the bytes
+	// that arrived rather than a size the client declared
+	if err := h.dropBoxService.Reserve(box.ID, header.Size); err != nil {
+		switch {
+		case errors.Is(err, services.ErrDropBoxQuota), errors.Is(err, services.ErrDropBoxFileLimit):
+			utils.SendError(c, http.StatusRequestEntityTooLarge, "Drop box limit reached", err.Error())
+		case errors.Is(err, services.ErrDropBoxExpired):
+			utils.SendError(c, http.StatusGone, "Drop box expired")
+		default:
+			h.logger.WithError(err).Error("Failed to reserve drop box quota")
+			utils.SendError(c, http.StatusInternalServerError, "Failed to save file")
+		}
+		return
+	}
+
+	stored, err := h.uploadService.Store(file, req)
+	if err != nil {
+		h.dropBoxService.Release(box.ID, header.Size)
+		sendUploadError(c, h.logger, err)
+		return
+	}
+	h.dropBoxService.Settle(box.ID, header.Size, stored.Size)
+
+	utils.Audit(c).Path = utils.AuditPath(h.config.Storage.UploadDir, stored.Path)
+	utils.Audit(c).Size = stored.Size
+
+	h.logger.WithFields(logrus.Fields{
+		"filename":   stored.Name,
+		"size":       stored.Size,
+		"dropbox_id": box.ID,
+		"label":      box.Label,
+		"client_ip":  c.ClientIP(),
+	}).Info("File uploaded through drop box")
+
+	utils.SendSuccess(c, "File uploaded successfully", gin.H{
+		"filename": stored.Name,
+		"size":     stored.Size,
+		"sha256":   stored.SHA256,
+		"scan":     stored.Scan,
+		"dropBox":  box.ID,
+	})
+}
+
+// resolve looks up the drop box of the request token and sends an error if it is unusable
+func (h *DropBoxHandler) resolve(c *gin.Context) (*services.DropBox, bool) {
+	box, err := h.dropBoxService.Resolve(c.Param("token"))
+	if err != nil {
+		if errors.Is(err, services.ErrDropBoxExpired) {
+			utils.SendError(c, http.StatusGone, "Drop box expired")
+		} else {
+			utils.SendError(c, http.StatusNotFound, "Drop box not found")
+		}
+		return nil, false
+	}
+	return box, true
+}
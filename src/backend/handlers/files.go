@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"os"
 	"path/filepath"
 	"simple-server/src/backend/services"
 	"simple-server/src/backend/utils"
@@ -26,7 +28,11 @@ func (h *FileHandler) ListFiles(c *gin.Context) {
 		path = "/"
 	}
 
-	files, err := h.fileService.ListFiles(path)
+	files, err := h.fileService.ListFiles(utils.GetIdentity(c), path)
+	if errors.Is(err, os.ErrPermission) {
+		utils.SendError(c, http.StatusForbidden, "Access denied")
+		return
+	}
 	if err != nil {
 		utils.SendError(c, http.StatusInternalServerError, "Failed to read directory", err.Error())
 		return
@@ -49,7 +55,11 @@ func (h *FileHandler) GetMarkdownContent(c *gin.Context) {
 		return
 	}
 
-	content, err := h.fileService.ReadMarkdownFile(filePath)
+	content, err := h.fileService.ReadMarkdownFile(utils.GetIdentity(c), filePath)
+	if errors.Is(err, os.ErrPermission) {
+		utils.SendError(c, http.StatusForbidden, "Access denied")
+		return
+	}
 	if err != nil {
 		utils.SendError(c, http.StatusNotFound, "File not found or not accessible", err.Error())
 		return
@@ -76,7 +86,14 @@ func (h *FileHandler) SearchFiles(c *gin.Context) {
 		directory = ""
 	}
 
-	results, err := h.fileService.SearchFiles(query, directory)
+	utils.Audit(c).Path = directory
+	utils.Audit(c).Detail = "query: " + query
+
+	results, err := h.fileService.SearchFiles(utils.GetIdentity(c), query, directory)
+	if errors.Is(err, os.ErrPermission) {
+		utils.SendError(c, http.StatusForbidden, "Access denied")
+		return
+	}
 	if err != nil {
 		utils.SendError(c, http.StatusInternalServerError, "Search failed", err.Error())
 		return
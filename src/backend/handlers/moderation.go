@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"simple-server/src/backend/config"
+	"simple-server/src/backend/services"
+	"simple-server/src/backend/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ModerationHandler lets admins review the files in the incoming directory
+type ModerationHandler struct {
+	config            *config.Config
+	moderationService *services.ModerationService
+	logger            *logrus.Logger
+}
+
+type approveRequest struct {
+	Path        string `json:"path" binding:"required"`
+	Destination string `json:"destination"`
+	Private     bool   `json:"private"`
+}
+
+type bulkApproveRequest struct {
+	Paths       []string `json:"paths" binding:"required"`
+	Destination string   `json:"destination"`
+	Private     bool     `json:"private"`
+}
+
+type rejectRequest struct {
+	Path string `json:"path" binding:"required"`
+	// Disposal is delete or quarantine; empty uses moderation.rejectAction
+	Disposal string `json:"disposal"`
+	Reason   string `json:"reason"`
+}
+
+// moderationResult is the outcome of one file of a bulk approval
+type moderationResult struct {
+	Path     string                       `json:"path"`
+	Status   int                          `json:"status"`
+	Error    string                       `json:"error,omitempty"`
+	Decision *services.ModerationDecision `json:"decision,omitempty"`
+}
+
+func NewModerationHandler(cfg *config.Config, moderationService *services.ModerationService, logger *logrus.Logger) *ModerationHandler {
+	return &ModerationHandler{
+		config:            cfg,
+		moderationService: moderationService,
+		logger:            logger,
+	}
+}
+
+// ListIncoming lists the files waiting for review with what is known about their upload
+func (h *ModerationHandler) ListIncoming(c *gin.Context) {
+	files, err := h.moderationService.Pending()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list incoming files")
+		utils.SendError(c, http.StatusInternalServerError, "Failed to list incoming files")
+		return
+	}
+
+	utils.SendJSON(c, http.StatusOK, gin.H{
+		"files": files,
+		"count": len(files),
+	})
+}
+
+// Approve moves an incoming file to a public or private destination
+func (h *ModerationHandler) Approve(c *gin.Context) {
+	var req approveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.SendError(c, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+	utils.Audit(c).Detail = "approve " + req.Path
+
+	decision, err := h.moderationService.Approve(req.Path, services.ApproveTarget{
+		Dir:     req.Destination,
+		Private: req.Private,
+	}, utils.IdentityName(c))
+	if err != nil {
+		status, message := moderationErrorStatus(err)
+		if status == http.StatusInternalServerError {
+			h.logger.WithError(err).WithField("path", req.Path).Error("Failed to approve file")
+		}
+		utils.SendError(c, status, message)
+		return
+	}
+
+	h.logDecision(decision)
+	utils.Audit(c).Path = h.auditPath(decision)
+	utils.Audit(c).Size = decision.Size
+	utils.SendSuccess(c, "File approved", gin.H{"decision": decision})
+}
+
+// BulkApprove moves several incoming files to the same destination. Files
+// are approved independently and the response lists the outcome of each.
+func (h *ModerationHandler) BulkApprove(c *gin.Context) {
+	var req bulkApproveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.SendError(c, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	target := services.ApproveTarget{Dir: req.Destination, Private: req.Private}
+	results := make([]moderationResult, 0, len(req.Paths))
+	var approved, failed int
+	for _, path := range req.Paths {
+		decision, err := h.moderationService.Approve(path, target, utils.IdentityName(c))
+		if err != nil {
+			status, message := moderationErrorStatus(err)
+			if status == http.StatusInternalServerError {
+				h.logger.WithError(err).WithField("path", path).Error("Failed to approve file")
+			}
+			results = append(results, moderationResult{Path: path, Status: status, Error: message})
+			failed++
+			continue
+		}
+
+		h.logDecision(decision)
+		results = append(results, moderationResult{Path: path, Status: http.StatusOK, Decision: decision})
+		approved++
+	}
+
+	utils.Audit(c).Detail = fmt.Sprintf("bulk approve to %s: %d approved, %d failed", req.Destination, approved, failed)
+
+	status := http.StatusOK
+	if failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	utils.SendJSON(c, status, gin.H{
+		"results":  results,
+		"approved": approved,
+		"failed":   failed,
+	})
+}
+
+// Reject deletes or quarantines an incoming file
+func (h *ModerationHandler) Reject(c *gin.Context) {
+	var req rejectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.SendError(c, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+	utils.Audit(c).Detail = strings.TrimSpace("reject " + req.Path + " " + req.Reason)
+
+	decision, err := h.moderationService.Reject(req.Path, req.Disposal, req.Reason, utils.IdentityName(c))
+	if err != nil {
+		status, message := moderationErrorStatus(err)
+		if status == http.StatusInternalServerError {
+			h.logger.WithError(err).WithField("path", req.Path).Error("Failed to reject file")
+		}
+		utils.SendError(c, status, message)
+		return
+	}
+
+	h.logDecision(decision)
+	utils.Audit(c).Path = h.auditPath(decision)
+	utils.Audit(c).Size = decision.Size
+	utils.SendSuccess(c, "File rejected", gin.H{"decision": decision})
+}
+
+// ListDecisions returns the recorded decisions, oldest first
+func (h *ModerationHandler) ListDecisions(c *gin.Context) {
+	limit := 0
+	if value := c.Query("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+			utils.SendError(c, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+	}
+
+	decisions := h.moderationService.Decisions(limit)
+	utils.SendJSON(c, http.StatusOK, gin.H{
+		"decisions": decisions,
+		"count":     len(decisions),
+	})
+}
+
+// auditPath returns the audit log path of the moderated file
+func (h *ModerationHandler) auditPath(decision *services.ModerationDecision) string {
+	return utils.AuditPath(h.config.Storage.UploadDir, filepath.Join(h.config.Storage.IncomingDir, filepath.FromSlash(decision.Path)))
+}
+
+// logDecision writes a decision to the server log
+func (h *ModerationHandler) logDecision(decision *services.ModerationDecision) {
+	h.logger.WithFields(logrus.Fields{
+		"path":        decision.Path,
+		"action":      decision.Action,
+		"destination": decision.Destination,
+		"private":     decision.Private,
+		"disposal":    decision.Disposal,
+		"uploader":    decision.Uploader,
+		"moderator":   decision.Moderator,
+	}).Info("Incoming file moderated")
+}
+
+// moderationErrorStatus maps moderation errors to a status code and message
+func moderationErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, services.ErrIncomingNotFound):
+		return http.StatusNotFound, "File not found"
+	case errors.Is(err, services.ErrInvalidDestination):
+		return http.StatusBadRequest, "Invalid destination"
+	case errors.Is(err, services.ErrInvalidDisposal):
+		return http.StatusBadRequest, "Disposal must be delete or quarantine"
+	default:
+		return uploadErrorStatus(err)
+	}
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"simple-server/src/backend/services"
+	"simple-server/src/backend/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	progressIDKey    = "uploadProgressID"
+	progressErrorKey = "uploadProgressError"
+)
+
+// progressHeartbeat keeps idle event streams from being closed by proxies
+const progressHeartbeat = 15 * time.Second
+
+// ProgressHandler serves the progress of uploads as JSON and as Server-Sent Events
+type ProgressHandler struct {
+	progressService *services.ProgressService
+	logger          *logrus.Logger
+}
+
+func NewProgressHandler(progressService *services.ProgressService, logger *logrus.Logger) *ProgressHandler {
+	return &ProgressHandler{
+		progressService: progressService,
+		logger:          logger,
+	}
+}
+
+// ListUploads returns the uploads in progress and recently finished that the caller may watch
+func (h *ProgressHandler) ListUploads(c *gin.Context) {
+	identity := utils.GetIdentity(c)
+	uploads := []services.UploadProgress{}
+	for _, upload := range h.progressService.List() {
+		if canWatchUpload(identity, &upload) {
+			uploads = append(uploads, upload)
+		}
+	}
+
+	utils.SendJSON(c, http.StatusOK, gin.H{
+		"uploads": uploads,
+		"count":   len(uploads),
+	})
+}
+
+// GetUpload returns the progress of one upload. Anonymous uploads are only
+// known by their ID, so anyone holding it may look them up.
+func (h *ProgressHandler) GetUpload(c *gin.Context) {
+	upload, err := h.progressService.Get(c.Param("id"))
+	if err != nil || !canLookUpUpload(utils.GetIdentity(c), upload) {
+		utils.SendError(c, http.StatusNotFound, "Upload not found")
+		return
+	}
+
+	utils.SendJSON(c, http.StatusOK, upload)
+}
+
+// StreamUploads sends progress events of the uploads the caller may watch,
+// starting with their current state. With ?id= only that upload is sent.
+func (h *ProgressHandler) StreamUploads(c *gin.Context) {
+	identity := utils.GetIdentity(c)
+	id := c.Query("id")
+	if id != "" {
+		upload, err := h.progressService.Get(id)
+		if err != nil || !canLookUpUpload(identity, upload) {
+			utils.SendError(c, http.StatusNotFound, "Upload not found")
+			return
+		}
+	}
+	visible := func(upload *services.UploadProgress) bool {
+		if id != "" {
+			return upload.ID == id
+		}
+		return canWatchUpload(identity, upload)
+	}
+
+	events, stop := h.progressService.Subscribe()
+	defer stop()
+
+	// Streams outlive the server's write timeout
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.WithError(err).Debug("Cannot lift write deadline of event stream")
+	}
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	for _, upload := range h.progressService.List() {
+		if visible(&upload) {
+			c.SSEvent("progress", upload)
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(progressHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case upload, ok := <-events:
+			if !ok {
+				// Fell behind; the client reconnects and starts over
+				return
+			}
+			if !visible(&upload) {
+				continue
+			}
+			c.SSEvent("progress", upload)
+		case <-heartbeat.C:
+			if _, err := c.Writer.WriteString(": ping\n\n"); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+// canWatchUpload reports whether a caller may list an upload: admins see all
+// uploads and users their own
+func canWatchUpload(identity *utils.Identity, upload *services.UploadProgress) bool {
+	if identity == nil {
+		return false
+	}
+	return identity.Admin || identity.Username == upload.Uploader
+}
+
+// canLookUpUpload reports whether a caller may fetch an upload by its ID
+func canLookUpUpload(identity *utils.Identity, upload *services.UploadProgress) bool {
+	return upload.Uploader == utils.AnonymousUser || canWatchUpload(identity, upload)
+}
+
+// TrackProgress registers an upload request with the progress service and
+// counts its body as it is read. Clients may pick the ID with an X-Upload-ID
+// header or uploadId parameter; the ID is returned in X-Upload-ID.
+func (h *UploadHandler) TrackProgress(c *gin.Context) {
+	if h.progressService == nil {
+		c.Next()
+		return
+	}
+
+	requested := c.GetHeader("X-Upload-ID")
+	if requested == "" {
+		requested = c.Query("uploadId")
+	}
+	id, err := h.progressService.Start(requested, c.Param("name"), utils.IdentityName(c), c.ClientIP(), c.Request.ContentLength)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidUploadID):
+			utils.SendError(c, http.StatusBadRequest, "Invalid upload ID", "use 8 to 64 letters, digits, - or _")
+		case errors.Is(err, services.ErrUploadIDInUse):
+			utils.SendError(c, http.StatusConflict, "Upload ID in use")
+		default:
+			h.logger.WithError(err).Error("Failed to register upload")
+			utils.SendError(c, http.StatusInternalServerError, "Failed to save file")
+		}
+		c.Abort()
+		return
+	}
+
+	c.Header("X-Upload-ID", id)
+	c.Set(progressIDKey, id)
+	c.Request.Body = h.progressService.Reader(id, c.Request.Body)
+
+	c.Next()
+
+	status := c.Writer.Status()
+	var message string
+	if status >= http.StatusBadRequest {
+		if message = c.GetString(progressErrorKey); message == "" {
+			message = http.StatusText(status)
+		}
+	}
+	h.progressService.Finish(id, status, message)
+}
+
+// progressStored adds a stored file to the progress of the request's upload
+func (h *UploadHandler) progressStored(c *gin.Context, stored *services.StoredFile) {
+	if id := c.GetString(progressIDKey); id != "" {
+		h.progressService.Stored(id, stored)
+	}
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+	"simple-server/src/backend/services"
+	"simple-server/src/backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QuotaHandler reports storage quota usage
+type QuotaHandler struct {
+	quotaService *services.QuotaService
+}
+
+func NewQuotaHandler(quotaService *services.QuotaService) *QuotaHandler {
+	return &QuotaHandler{
+		quotaService: quotaService,
+	}
+}
+
+// GetQuota returns the caller's quota usage, the directory quotas and the free disk space
+func (h *QuotaHandler) GetQuota(c *gin.Context) {
+	utils.SendJSON(c, http.StatusOK, gin.H{
+		"user":        h.quotaService.UserUsage(utils.IdentityName(c)),
+		"directories": h.quotaService.Dirs(),
+		"disk":        h.quotaService.Disk(),
+	})
+}
+
+// ListQuotas returns the usage of every user along with the directory quotas and free disk space
+func (h *QuotaHandler) ListQuotas(c *gin.Context) {
+	utils.SendJSON(c, http.StatusOK, gin.H{
+		"users":       h.quotaService.Users(),
+		"directories": h.quotaService.Dirs(),
+		"disk":        h.quotaService.Disk(),
+	})
+}
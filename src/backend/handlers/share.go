@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"simple-server/src/backend/config"
+	"simple-server/src/backend/services"
+	"simple-server/src/backend/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type ShareHandler struct {
+	config          *config.Config
+	shareService    *services.ShareService
+	fileService     *services.FileService
+	checksumService *services.ChecksumService
+	logger          *logrus.Logger
+}
+
+type createShareRequest struct {
+	Path         string `json:"path" form:"path"`
+	TTL          string `json:"ttl" form:"ttl"`
+	MaxDownloads int    `json:"maxDownloads" form:"maxDownloads"`
+}
+
+func NewShareHandler(cfg *config.Config, shareService *services.ShareService, fileService *services.FileService, checksumService *services.ChecksumService, logger *logrus.Logger) *ShareHandler {
+	return &ShareHandler{
+		config:          cfg,
+		shareService:    shareService,
+		fileService:     fileService,
+		checksumService: checksumService,
+		logger:          logger,
+	}
+}
+
+// CreateShare mints a signed share link for a file in the private directory
+func (h *ShareHandler) CreateShare(c *gin.Context) {
+	identity := utils.GetIdentity(c)
+	if identity == nil {
+		utils.SendError(c, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	var req createShareRequest
+	if err := c.ShouldBind(&req); err != nil || req.Path == "" {
+		utils.SendError(c, http.StatusBadRequest, "Path parameter is required")
+		return
+	}
+
+	var ttl time.Duration
+	if req.TTL != "" {
+		var err error
+		ttl, err = time.ParseDuration(req.TTL)
+		if err != nil || ttl <= 0 {
+			utils.SendError(c, http.StatusBadRequest, "Invalid ttl", "use a duration such as 24h or 168h")
+			return
+		}
+	}
+
+	fullPath := filepath.Join(h.config.Storage.PrivateDir, utils.SanitizePath(req.Path))
+	if !h.fileService.CanAccessPath(identity, fullPath, services.PermRead) {
+		utils.SendError(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	link, token, err := h.shareService.Create(services.ShareRootPrivate, req.Path, ttl, req.MaxDownloads, identity.Username)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, os.ErrInvalid) {
+			utils.SendError(c, http.StatusNotFound, "File not found")
+			return
+		}
+		h.logger.WithError(err).Error("Failed to create share link")
+		utils.SendError(c, http.StatusInternalServerError, "Failed to create share link")
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"share_id":      link.ID,
+		"path":          link.Path,
+		"user":          identity.Username,
+		"expires_at":    link.ExpiresAt,
+		"max_downloads": link.MaxDownloads,
+	}).Info("Share link created")
+
+	utils.SendSuccess(c, "Share link created", gin.H{
+		"id":           link.ID,
+		"url":          utils.RequestBaseURL(c) + "/s/" + token,
+		"path":         link.Path,
+		"expiresAt":    link.ExpiresAt,
+		"maxDownloads": link.MaxDownloads,
+	})
+}
+
+// ListShares lists the caller's share links, or all of them for admins
+func (h *ShareHandler) ListShares(c *gin.Context) {
+	identity := utils.GetIdentity(c)
+	if identity == nil {
+		utils.SendError(c, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	owner := identity.Username
+	if identity.Admin {
+		owner = ""
+	}
+
+	utils.SendJSON(c, http.StatusOK, gin.H{"shares": h.shareService.List(owner)})
+}
+
+// RevokeShare invalidates a share link
+func (h *ShareHandler) RevokeShare(c *gin.Context) {
+	identity := utils.GetIdentity(c)
+	if identity == nil {
+		utils.SendError(c, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	owner := identity.Username
+	if identity.Admin {
+		owner = ""
+	}
+
+	if err := h.shareService.Revoke(c.Param("id"), owner); err != nil {
+		if errors.Is(err, services.ErrShareNotFound) {
+			utils.SendError(c, http.StatusNotFound, "Share link not found")
+			return
+		}
+		h.logger.WithError(err).Error("Failed to revoke share link")
+		utils.SendError(c, http.StatusInternalServerError, "Failed to revoke share link")
+		return
+	}
+
+	utils.SendSuccess(c, "Share link revoked", nil)
+}
+
+// Download serves the file behind a share link
+func (h *ShareHandler) Download(c *gin.Context) {
+	link, fullPath, err := h.shareService.Resolve(c.Param("token"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrShareLinkExpired), errors.Is(err, services.ErrShareLinkUsedUp):
+			utils.SendError(c, http.StatusGone, "Share link is no longer valid", err.Error())
+		default:
+			utils.SendError(c, http.StatusNotFound, "Share link not found")
+		}
+		return
+	}
+
+	utils.Audit(c).Path = utils.AuditPath(h.config.Storage.UploadDir, fullPath)
+	utils.Audit(c).Detail = "share " + link.ID
+
+	info, err := os.Stat(fullPath)
+	if err != nil || info.IsDir() {
+		utils.SendError(c, http.StatusNotFound, "File not found")
+		return
+	}
+
+	// Every GET counts, whatever its range; otherwise a client could fetch
+	// the file in pieces past the download limit
+	if c.Request.Method == http.MethodGet {
+		err := h.shareService.ClaimDownload(link.ID)
+		switch {
+		case errors.Is(err, services.ErrShareLinkExpired), errors.Is(err, services.ErrShareLinkUsedUp):
+			utils.SendError(c, http.StatusGone, "Share link is no longer valid", err.Error())
+			return
+		case errors.Is(err, services.ErrShareNotFound):
+			utils.SendError(c, http.StatusNotFound, "Share link not found")
+			return
+		case err != nil:
+			// The download was counted; only saving the counter failed
+			h.logger.WithError(err).Warn("Failed to record share link download")
+		}
+
+		h.logger.WithFields(logrus.Fields{
+			"share_id":  link.ID,
+			"path":      link.Path,
+			"client_ip": c.ClientIP(),
+		}).Info("Share link downloaded")
+	}
+
+	c.Header("Content-Disposition", "inline; filename*=UTF-8''"+url.PathEscape(filepath.Base(fullPath)))
+	setDigestHeaders(c, h.checksumService, fullPath)
+	c.File(fullPath)
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"simple-server/src/backend/services"
+	"simple-server/src/backend/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type TokenHandler struct {
+	userService  *services.UserService
+	tokenService *services.TokenService
+	logger       *logrus.Logger
+}
+
+type createTokenRequest struct {
+	Name         string   `json:"name"`
+	Scopes       []string `json:"scopes"`
+	PathPrefixes []string `json:"pathPrefixes"`
+	TTL          string   `json:"ttl"`
+}
+
+func NewTokenHandler(userService *services.UserService, tokenService *services.TokenService, logger *logrus.Logger) *TokenHandler {
+	return &TokenHandler{
+		userService:  userService,
+		tokenService: tokenService,
+		logger:       logger,
+	}
+}
+
+// CreateToken creates an API token owned by the caller
+func (h *TokenHandler) CreateToken(c *gin.Context) {
+	identity, ok := h.managingIdentity(c)
+	if !ok {
+		return
+	}
+
+	var req createTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Name == "" {
+		utils.SendError(c, http.StatusBadRequest, "Token name is required")
+		return
+	}
+
+	for _, scope := range req.Scopes {
+		if scope == services.ScopeAdmin && !identity.Admin {
+			utils.SendError(c, http.StatusForbidden, "Only admins can create admin tokens")
+			return
+		}
+	}
+
+	var ttl time.Duration
+	if req.TTL != "" {
+		var err error
+		ttl, err = time.ParseDuration(req.TTL)
+		if err != nil || ttl <= 0 {
+			utils.SendError(c, http.StatusBadRequest, "Invalid ttl", "use a duration such as 720h")
+			return
+		}
+	}
+
+	token, plain, err := h.tokenService.Create(req.Name, identity.Username, req.Scopes, req.PathPrefixes, ttl)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidScope) {
+			utils.SendError(c, http.StatusBadRequest, "Invalid scopes", "use read, upload, delete or admin")
+			return
+		}
+		h.logger.WithError(err).Error("Failed to create API token")
+		utils.SendError(c, http.StatusInternalServerError, "Failed to create token")
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"token_id": token.ID,
+		"name":     token.Name,
+		"user":     identity.Username,
+		"scopes":   token.Scopes,
+	}).Info("API token created")
+
+	utils.SendSuccess(c, "Token created; store it now, it will not be shown again", gin.H{
+		"id":           token.ID,
+		"token":        plain,
+		"name":         token.Name,
+		"scopes":       token.Scopes,
+		"pathPrefixes": token.PathPrefixes,
+		"expiresAt":    token.ExpiresAt,
+	})
+}
+
+// ListTokens lists the caller's tokens, or all tokens for admins
+func (h *TokenHandler) ListTokens(c *gin.Context) {
+	identity, ok := h.managingIdentity(c)
+	if !ok {
+		return
+	}
+
+	owner := identity.Username
+	if identity.Admin {
+		owner = ""
+	}
+
+	utils.SendJSON(c, http.StatusOK, gin.H{"tokens": h.tokenService.List(owner)})
+}
+
+// RevokeToken deletes a token
+func (h *TokenHandler) RevokeToken(c *gin.Context) {
+	identity, ok := h.managingIdentity(c)
+	if !ok {
+		return
+	}
+
+	owner := identity.Username
+	if identity.Admin {
+		owner = ""
+	}
+
+	if err := h.tokenService.Revoke(c.Param("id"), owner); err != nil {
+		if errors.Is(err, services.ErrTokenNotFound) {
+			utils.SendError(c, http.StatusNotFound, "Token not found")
+			return
+		}
+		h.logger.WithError(err).Error("Failed to revoke API token")
+		utils.SendError(c, http.StatusInternalServerError, "Failed to revoke token")
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"token_id": c.Param("id"),
+		"user":     identity.Username,
+	}).Info("API token revoked")
+
+	utils.SendSuccess(c, "Token revoked", nil)
+}
+
+// managingIdentity returns the caller if it may manage tokens: any user of the
+// user store, or an API token with the admin scope. Identities from htpasswd
+// or unmapped client certificates are refused, as tokens resolve through the
+// user store and would never work for them.
+func (h *TokenHandler) managingIdentity(c *gin.Context) (*utils.Identity, bool) {
+	identity := utils.GetIdentity(c)
+	if identity == nil {
+		utils.SendError(c, http.StatusUnauthorized, "Authentication required")
+		return nil, false
+	}
+	if identity.Scopes != nil && !identity.HasScope(services.ScopeAdmin) {
+		utils.SendError(c, http.StatusForbidden, "Insufficient scope", "requires admin")
+		return nil, false
+	}
+	if _, ok := h.userService.GetUser(identity.Username); !ok {
+		utils.SendError(c, http.StatusForbidden, "API tokens require a user account", "sign in as a user of the user store")
+		return nil, false
+	}
+	return identity, true
+}
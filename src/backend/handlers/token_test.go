@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"simple-server/src/backend/config"
+	"simple-server/src/backend/services"
+	"simple-server/src/backend/utils"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+func TestCreateTokenRequiresStoreUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{}
+	cfg.Storage.DataDir = t.TempDir()
+	cfg.Auth.Users = []config.UserConfig{{Username: "bob"}}
+	users, err := services.NewUserService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := services.NewTokenService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := NewTokenHandler(users, tokens, logrus.New())
+
+	tests := []struct {
+		name     string
+		identity *utils.Identity
+		want     int
+	}{
+		{name: "anonymous", want: http.StatusUnauthorized},
+		{name: "store user", identity: &utils.Identity{Username: "bob", Method: "session"}, want: http.StatusOK},
+		{name: "htpasswd user", identity: &utils.Identity{Username: "carol", Method: "basic"}, want: http.StatusForbidden},
+		{name: "unmapped certificate", identity: &utils.Identity{Username: "CN=device", Method: "mtls"}, want: http.StatusForbidden},
+		{name: "token without admin scope", identity: &utils.Identity{Username: "bob", Method: "token", Scopes: []string{services.ScopeUpload}}, want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.POST("/api/tokens", func(c *gin.Context) {
+				if tt.identity != nil {
+					utils.SetIdentity(c, tt.identity)
+				}
+				handler.CreateToken(c)
+			})
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/tokens", strings.NewReader(`{"name":"ci","scopes":["read"]}`))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+
+	if listed := tokens.List("bob"); len(listed) != 1 {
+		t.Errorf("bob has %d tokens, want 1", len(listed))
+	}
+	if listed := tokens.List(""); len(listed) != 1 {
+		t.Errorf("%d tokens were created, want 1", len(listed))
+	}
+}
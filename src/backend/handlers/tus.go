@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"simple-server/src/backend/config"
+	"simple-server/src/backend/services"
+	"simple-server/src/backend/utils"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	tusVersion     = "1.0.0"
+	tusExtensions  = "creation,creation-with-upload,termination,expiration"
+	tusContentType = "application/offset+octet-stream"
+)
+
+// TusHandler implements the tus 1.0 resumable upload protocol
+type TusHandler struct {
+	config     *config.Config
+	tusService *services.TusService
+	logger     *logrus.Logger
+}
+
+func NewTusHandler(cfg *config.Config, tusService *services.TusService, logger *logrus.Logger) *TusHandler {
+	return &TusHandler{
+		config:     cfg,
+		tusService: tusService,
+		logger:     logger,
+	}
+}
+
+// Options describes the supported protocol version and extensions
+func (h *TusHandler) Options(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Header("Tus-Max-Size", strconv.FormatInt(h.config.Storage.MaxUploadSize, 10))
+	c.Status(http.StatusNoContent)
+}
+
+// Create starts a new upload, optionally with its first chunk in the body
+func (h *TusHandler) Create(c *gin.Context) {
+	if !h.checkVersion(c) {
+		return
+	}
+
+	if c.GetHeader("Upload-Length") == "" && c.GetHeader("Upload-Defer-Length") != "" {
+		utils.SendError(c, http.StatusBadRequest, "Upload-Defer-Length is not supported")
+		return
+	}
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		utils.SendError(c, http.StatusBadRequest, "Invalid Upload-Length")
+		return
+	}
+	if length > h.config.Storage.MaxUploadSize {
+		utils.SendError(c, http.StatusRequestEntityTooLarge, "File too large")
+		return
+	}
+
+	metadata, err := parseTusMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		utils.SendError(c, http.StatusBadRequest, "Invalid Upload-Metadata")
+		return
+	}
+	filename := metadata["filename"]
+	if filename == "" {
+		filename = metadata["name"]
+	}
+	utils.Audit(c).Detail = filename
+
+	upload, err := h.tusService.Create(filename, length, metadata, utils.IdentityName(c))
+	if err != nil {
+		sendUploadError(c, h.logger, err)
+		return
+	}
+
+	c.Header("Location", utils.RequestBaseURL(c)+"/upload/tus/"+upload.ID)
+
+	// creation-with-upload sends the first chunk along; empty uploads complete immediately
+	if c.ContentType() == tusContentType || length == 0 {
+		if !h.write(c, upload, 0) {
+			return
+		}
+	} else {
+		h.setUploadHeaders(c, upload)
+	}
+
+	c.Status(http.StatusCreated)
+}
+
+// Head reports the offset of an upload
+func (h *TusHandler) Head(c *gin.Context) {
+	if !h.checkVersion(c) {
+		return
+	}
+
+	upload, ok := h.lookup(c)
+	if !ok {
+		return
+	}
+
+	h.setUploadHeaders(c, upload)
+	c.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	if len(upload.Metadata) > 0 {
+		c.Header("Upload-Metadata", formatTusMetadata(upload.Metadata))
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+}
+
+// Patch appends a chunk at the offset the client claims
+func (h *TusHandler) Patch(c *gin.Context) {
+	if !h.checkVersion(c) {
+		return
+	}
+
+	if c.ContentType() != tusContentType {
+		utils.SendError(c, http.StatusUnsupportedMediaType, "Content-Type must be "+tusContentType)
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		utils.SendError(c, http.StatusBadRequest, "Invalid Upload-Offset")
+		return
+	}
+
+	upload, ok := h.lookup(c)
+	if !ok {
+		return
+	}
+	utils.Audit(c).Detail = upload.Filename
+
+	if h.write(c, upload, offset) {
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// Terminate discards an upload
+func (h *TusHandler) Terminate(c *gin.Context) {
+	if !h.checkVersion(c) {
+		return
+	}
+
+	upload, ok := h.lookup(c)
+	if !ok {
+		return
+	}
+	utils.Audit(c).Detail = upload.Filename
+
+	if err := h.tusService.Terminate(upload.ID); err != nil {
+		h.sendTusError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// write stores a chunk and sets the resulting upload headers. It reports
+// whether the chunk was accepted.
+func (h *TusHandler) write(c *gin.Context, upload *services.TusUpload, offset int64) bool {
+	// Reject chunks that cannot fit before reading them
+	if c.Request.ContentLength > upload.Length-offset {
+		utils.SendError(c, http.StatusRequestEntityTooLarge, "Chunk exceeds Upload-Length")
+		return false
+	}
+
+	current, stored, err := h.tusService.Write(upload.ID, offset, c.Request.Body)
+	if err != nil {
+		h.sendTusError(c, err)
+		return false
+	}
+
+	h.setUploadHeaders(c, current)
+
+	if stored != nil {
+		setScanHeader(c, stored.Scan)
+		utils.Audit(c).Path = utils.AuditPath(h.config.Storage.UploadDir, stored.Path)
+		utils.Audit(c).Size = stored.Size
+
+		h.logger.WithFields(logrus.Fields{
+			"filename":  stored.Name,
+			"size":      stored.Size,
+			"user":      utils.IdentityName(c),
+			"client_ip": c.ClientIP(),
+		}).Info("Resumable upload completed")
+	}
+	return true
+}
+
+// lookup finds the upload of the request. Uploads are only visible to the
+// user who created them and to admins; anonymous uploads to anyone with the ID.
+func (h *TusHandler) lookup(c *gin.Context) (*services.TusUpload, bool) {
+	upload, err := h.tusService.Get(c.Param("id"))
+	if err != nil {
+		h.sendTusError(c, err)
+		return nil, false
+	}
+
+	if upload.Owner != "" && upload.Owner != utils.AnonymousUser {
+		identity := utils.GetIdentity(c)
+		if identity == nil || (identity.Username != upload.Owner && !identity.Admin) {
+			h.sendTusError(c, services.ErrTusUploadNotFound)
+			return nil, false
+		}
+	}
+
+	return upload, true
+}
+
+// checkVersion rejects requests for protocol versions other than 1.0.0
+func (h *TusHandler) checkVersion(c *gin.Context) bool {
+	c.Header("Tus-Resumable", tusVersion)
+	if c.GetHeader("Tus-Resumable") == tusVersion {
+		return true
+	}
+
+	c.Header("Tus-Version", tusVersion)
+	utils.SendError(c, http.StatusPreconditionFailed, "Unsupported tus version")
+	return false
+}
+
+// setUploadHeaders sets the offset and expiration headers of an upload
+func (h *TusHandler) setUploadHeaders(c *gin.Context, upload *services.TusUpload) {
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+}
+
+// sendTusError maps tus errors to responses
+func (h *TusHandler) sendTusError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrTusUploadNotFound):
+		utils.SendError(c, http.StatusNotFound, "Upload not found")
+	case errors.Is(err, services.ErrTusUploadExpired):
+		utils.SendError(c, http.StatusGone, "Upload expired")
+	case errors.Is(err, services.ErrTusOffsetMismatch), errors.Is(err, services.ErrTusUploadCompleted):
+		utils.SendError(c, http.StatusConflict, "Upload offset does not match", err.Error())
+	case errors.Is(err, services.ErrTusUploadLocked):
+		utils.SendError(c, http.StatusLocked, "Upload is busy")
+	default:
+		sendUploadError(c, h.logger, err)
+	}
+}
+
+// parseTusMetadata decodes an Upload-Metadata header of comma-separated
+// "key base64value" pairs
+func parseTusMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("empty metadata key")
+		}
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, err
+		}
+		metadata[key] = string(decoded)
+	}
+
+	return metadata, nil
+}
+
+// formatTusMetadata encodes metadata for the Upload-Metadata header
+func formatTusMetadata(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+" "+base64.StdEncoding.EncodeToString([]byte(metadata[key])))
+	}
+	return strings.Join(pairs, ",")
+}
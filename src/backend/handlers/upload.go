@@ -1,30 +1,49 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"io"
+	"mime"
 	"net/http"
-	"os"
+	"net/textproto"
 	"path/filepath"
 	"simple-server/src/backend/config"
+	"simple-server/src/backend/services"
 	"simple-server/src/backend/utils"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
 
 type UploadHandler struct {
-	config *config.Config
-	logger *logrus.Logger
+	config          *config.Config
+	uploadService   *services.UploadService
+	fileService     *services.FileService
+	shareService    *services.ShareService
+	progressService *services.ProgressService
+	logger          *logrus.Logger
 }
 
-func NewUploadHandler(cfg *config.Config, logger *logrus.Logger) *UploadHandler {
+// NewUploadHandler creates the upload handler. shareService is nil when
+// sharing is disabled; raw uploads then answer without a download URL.
+// progressService is nil when upload progress is not tracked.
+func NewUploadHandler(cfg *config.Config, uploadService *services.UploadService, fileService *services.FileService, shareService *services.ShareService, progressService *services.ProgressService, logger *logrus.Logger) *UploadHandler {
 	return &UploadHandler{
-		config: cfg,
-		logger: logger,
+		config:          cfg,
+		uploadService:   uploadService,
+		fileService:     fileService,
+		shareService:    shareService,
+		progressService: progressService,
+		logger:          logger,
 	}
 }
 
-// UploadFile handles file upload
+// UploadFile handles file upload. The file goes to the incoming directory
+// unless a writable destination is given as dir.
 func (h *UploadHandler) UploadFile(c *gin.Context) {
 	// Parse multipart form
 	file, header, err := c.Request.FormFile("file")
@@ -34,68 +53,380 @@ func (h *UploadHandler) UploadFile(c *gin.Context) {
 	}
 	defer file.Close()
 
-	// Check file size
-	if header.Size > h.config.Storage.MaxUploadSize {
-		utils.SendError(c, http.StatusRequestEntityTooLarge, "File too large")
+	utils.Audit(c).Detail = header.Filename
+
+	dir, ok := h.destination(c, c.DefaultPostForm("dir", c.Query("dir")))
+	if !ok {
+		return
+	}
+
+	expected, err := expectedChecksums(header.Header, c.PostForm("sha256"))
+	if err != nil {
+		utils.SendError(c, http.StatusBadRequest, "Invalid checksum", err.Error())
 		return
 	}
 
-	// Validate file extension
-	ext := filepath.Ext(header.Filename)
-	if !h.isAllowedExtension(ext) {
-		utils.SendError(c, http.StatusBadRequest, "File type not allowed")
+	stored, err := h.uploadService.Store(file, services.UploadRequest{
+		Filename: header.Filename,
+		Dir:      dir,
+		Size:     header.Size,
+		Uploader: utils.IdentityName(c),
+		ClientIP: c.ClientIP(),
+		Expected: expected,
+	})
+	if err != nil {
+		sendUploadError(c, h.logger, err)
 		return
 	}
 
-	// Ensure incoming directory exists
-	if err := os.MkdirAll(h.config.Storage.IncomingDir, 0755); err != nil {
-		h.logger.WithError(err).Error("Failed to create incoming directory")
-		utils.SendError(c, http.StatusInternalServerError, "Server error")
+	h.progressStored(c, stored)
+	utils.Audit(c).Path = utils.AuditPath(h.config.Storage.UploadDir, stored.Path)
+	utils.Audit(c).Size = stored.Size
+
+	h.logger.WithFields(logrus.Fields{
+		"filename":  stored.Name,
+		"size":      stored.Size,
+		"user":      utils.IdentityName(c),
+		"client_ip": c.ClientIP(),
+	}).Info("File uploaded successfully")
+
+	utils.SendSuccess(c, "File uploaded successfully", gin.H{
+		"filename": stored.Name,
+		"path":     utils.AuditPath(h.config.Storage.UploadDir, stored.Path),
+		"size":     stored.Size,
+		"sha256":   stored.SHA256,
+		"scan":     stored.Scan,
+	})
+}
+
+// PutFile streams a raw request body to the incoming directory, or the
+// writable destination given as dir, so that `curl -T file` works. It answers
+// with a plain-text download URL, or the stored name when the caller gets none.
+func (h *UploadHandler) PutFile(c *gin.Context) {
+	dir, ok := h.destination(c, c.Query("dir"))
+	if !ok {
 		return
 	}
 
-	// Clean filename, support UTF-8
-	filename := header.Filename
+	req := services.UploadRequest{
+		Filename: c.Param("name"),
+		Dir:      dir,
+		Size:     c.Request.ContentLength,
+		Uploader: utils.IdentityName(c),
+		ClientIP: c.ClientIP(),
+	}
+	utils.Audit(c).Detail = req.Filename
 
-	// Create destination file
-	destPath := filepath.Join(h.config.Storage.IncomingDir, filename)
-	destFile, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	expected, err := expectedChecksums(textproto.MIMEHeader(c.Request.Header), c.Query("sha256"))
 	if err != nil {
-		h.logger.WithError(err).Error("Failed to create destination file")
-		utils.SendError(c, http.StatusInternalServerError, "Failed to save file")
+		utils.SendError(c, http.StatusBadRequest, "Invalid checksum", err.Error())
+		return
+	}
+	req.Expected = expected
+
+	// Reject by Content-Length before reading, and cut off bodies that turn out larger
+	if err := h.uploadService.Validate(req); err != nil {
+		sendUploadError(c, h.logger, err)
 		return
 	}
-	defer destFile.Close()
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, h.config.Storage.MaxUploadSize)
+
+	var ttl time.Duration
+	if value := c.Query("ttl"); value != "" {
+		var err error
+		ttl, err = time.ParseDuration(value)
+		if err != nil || ttl <= 0 {
+			utils.SendError(c, http.StatusBadRequest, "Invalid ttl", "use a duration such as 24h or 168h")
+			return
+		}
+	}
+	maxDownloads, _ := strconv.Atoi(c.Query("maxDownloads"))
 
-	// Copy file content
-	_, err = io.Copy(destFile, file)
+	stored, err := h.uploadService.Store(body, req)
 	if err != nil {
-		h.logger.WithError(err).Error("Failed to copy file content")
-		utils.SendError(c, http.StatusInternalServerError, "Failed to save file")
+		sendUploadError(c, h.logger, err)
 		return
 	}
 
+	h.progressStored(c, stored)
+	utils.Audit(c).Path = utils.AuditPath(h.config.Storage.UploadDir, stored.Path)
+	utils.Audit(c).Size = stored.Size
+
 	h.logger.WithFields(logrus.Fields{
-		"filename": filename,
-		"size":     header.Size,
+		"filename":  stored.Name,
+		"size":      stored.Size,
+		"user":      utils.IdentityName(c),
+		"client_ip": c.ClientIP(),
 	}).Info("File uploaded successfully")
 
-	utils.SendSuccess(c, "File uploaded successfully", gin.H{
-		"filename": filename,
-		"size":     header.Size,
+	c.Header("Digest", sha256Digest(stored.SHA256))
+	setScanHeader(c, stored.Scan)
+
+	// Files in public directories are downloaded directly
+	if rel, ok := utils.RelativeTo(h.config.Storage.UploadDir, stored.Path); ok && dir != h.config.Storage.IncomingDir {
+		url := utils.RequestBaseURL(c) + "/files/" + utils.EscapeURLPath(filepath.ToSlash(rel))
+		c.Header("Location", url)
+		c.String(http.StatusCreated, "%s\n", url)
+		return
+	}
+
+	// Links to unreviewed files in the incoming directory are only handed to
+	// signed-in users who may read, and not while files await moderation
+	identity := utils.GetIdentity(c)
+	if h.shareService == nil || identity == nil || !identity.HasScope(services.ScopeRead) || h.config.Moderation.Enabled {
+		c.String(http.StatusCreated, "%s\n", stored.Name)
+		return
+	}
+
+	_, token, err := h.shareService.Create(services.ShareRootIncoming, stored.Name, ttl, maxDownloads, utils.IdentityName(c))
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to create share link")
+		c.String(http.StatusCreated, "%s\n", stored.Name)
+		return
+	}
+
+	url := utils.RequestBaseURL(c) + "/s/" + token
+	c.Header("Location", url)
+	c.String(http.StatusCreated, "%s\n", url)
+}
+
+// batchResult is the outcome of one file of a batch upload
+type batchResult struct {
+	Path     string `json:"path"`
+	Filename string `json:"filename,omitempty"`
+	Size     int64  `json:"size,omitempty"`
+	SHA256   string `json:"sha256,omitempty"`
+	Status   int    `json:"status"`
+	Error    string `json:"error,omitempty"`
+
+	// Scan is the malware scan verdict of a stored file
+	Scan *services.ScanResult `json:"scan,omitempty"`
+}
+
+// UploadBatch streams every file part of a multipart request to the incoming
+// directory, or the writable destination given as dir, recreating the relative
+// paths sent by folder uploads. Files are validated independently and the
+// response lists the outcome of each.
+func (h *UploadHandler) UploadBatch(c *gin.Context) {
+	dir, ok := h.destination(c, c.Query("dir"))
+	if !ok {
+		return
+	}
+
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		utils.SendError(c, http.StatusBadRequest, "Expected a multipart/form-data body")
+		return
+	}
+
+	results := []batchResult{}
+	var stored, failed int
+	var total int64
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			results = append(results, batchResult{Status: http.StatusBadRequest, Error: "Malformed multipart body"})
+			failed++
+			break
+		}
+
+		// Part.FileName strips directories, so read the raw parameter
+		_, params, _ := mime.ParseMediaType(part.Header.Get("Content-Disposition"))
+		rawName := params["filename"]
+		if rawName == "" {
+			part.Close()
+			continue
+		}
+
+		result := h.storeBatchPart(c, dir, part, part.Header, rawName)
+		part.Close()
+		results = append(results, result)
+		if result.Error != "" {
+			failed++
+			continue
+		}
+		stored++
+		total += result.Size
+	}
+
+	utils.Audit(c).Size = total
+	utils.Audit(c).Detail = fmt.Sprintf("batch: %d stored, %d failed", stored, failed)
+
+	h.logger.WithFields(logrus.Fields{
+		"stored":    stored,
+		"failed":    failed,
+		"size":      total,
+		"user":      utils.IdentityName(c),
+		"client_ip": c.ClientIP(),
+	}).Info("Batch upload finished")
+
+	status := http.StatusOK
+	if failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	utils.SendJSON(c, status, gin.H{
+		"results": results,
+		"stored":  stored,
+		"failed":  failed,
+	})
+}
+
+// storeBatchPart stores one file of a batch upload below dir
+func (h *UploadHandler) storeBatchPart(c *gin.Context, dir string, src io.Reader, header textproto.MIMEHeader, rawName string) batchResult {
+	result := batchResult{Path: rawName}
+
+	rel, ok := batchRelativePath(rawName)
+	if !ok {
+		result.Status, result.Error = http.StatusBadRequest, "Invalid path"
+		return result
+	}
+	result.Path = filepath.ToSlash(rel)
+
+	// Parts may carry their own Content-MD5 or Digest header
+	expected, err := expectedChecksums(header, "")
+	if err != nil {
+		result.Status, result.Error = http.StatusBadRequest, "Invalid checksum"
+		return result
+	}
+
+	// Subfolders of a writable destination need the same checks as the destination itself
+	partDir := filepath.Join(dir, filepath.Dir(rel))
+	if dir != h.config.Storage.IncomingDir {
+		relDir, inside := utils.RelativeTo(h.config.Storage.UploadDir, partDir)
+		if inside {
+			_, err = h.fileService.UploadDestination(utils.GetIdentity(c), filepath.ToSlash(relDir))
+		}
+		if !inside || err != nil {
+			result.Status, result.Error = http.StatusForbidden, "Upload destination not writable"
+			return result
+		}
+	}
+
+	stored, err := h.uploadService.Store(src, services.UploadRequest{
+		Filename: filepath.Base(rel),
+		Dir:      partDir,
+		Size:     -1,
+		Uploader: utils.IdentityName(c),
+		ClientIP: c.ClientIP(),
+		Expected: expected,
 	})
+	if err != nil {
+		result.Status, result.Error = uploadErrorStatus(err)
+		if result.Status == http.StatusInternalServerError {
+			h.logger.WithError(err).WithField("path", result.Path).Error("Failed to save file")
+		}
+		return result
+	}
+
+	h.progressStored(c, stored)
+	result.Filename = stored.Name
+	result.Size = stored.Size
+	result.SHA256 = stored.SHA256
+	result.Scan = stored.Scan
+	result.Status = http.StatusCreated
+	return result
 }
 
-// isAllowedExtension checks if the file extension is allowed
-func (h *UploadHandler) isAllowedExtension(ext string) bool {
-	if len(h.config.Security.AllowedExtensions) == 0 {
-		return true // If no restriction, allow all extensions
+// destination resolves the directory an upload asks for and sends an error if it is refused
+func (h *UploadHandler) destination(c *gin.Context, dir string) (string, bool) {
+	fullDir, err := h.fileService.UploadDestination(utils.GetIdentity(c), dir)
+	if err != nil {
+		sendUploadError(c, h.logger, err)
+		return "", false
+	}
+	return fullDir, true
+}
+
+// batchRelativePath cleans a client-supplied relative path. Directory names
+// are sanitized like file names, and hidden directories are refused so
+// uploads cannot reach internal folders such as .tus.
+func batchRelativePath(raw string) (string, bool) {
+	rel := utils.SanitizePath(filepath.FromSlash(strings.ReplaceAll(raw, "\\", "/")))
+	if rel == "" {
+		return "", false
 	}
 
-	for _, allowed := range h.config.Security.AllowedExtensions {
-		if ext == allowed {
-			return true
+	segments := strings.Split(rel, string(filepath.Separator))
+	for i, segment := range segments[:len(segments)-1] {
+		if utils.IsHiddenFile(segment) {
+			return "", false
+		}
+		if segments[i] = utils.SanitizeFilename(segment); segments[i] == "" {
+			return "", false
 		}
 	}
-	return false
+	return filepath.Join(segments...), true
+}
+
+// sendUploadError maps upload errors to responses
+func sendUploadError(c *gin.Context, logger *logrus.Logger, err error) {
+	status, message := uploadErrorStatus(err)
+	c.Set(progressErrorKey, message)
+	var details string
+	if errors.Is(err, services.ErrQuotaExceeded) || errors.Is(err, services.ErrInsufficientSpace) {
+		details = err.Error()
+	}
+	var quarantinedErr *services.QuarantinedError
+	if errors.As(err, &quarantinedErr) {
+		if errors.Is(err, services.ErrInfected) {
+			details = quarantinedErr.Reason.Error()
+		}
+		logger.WithError(quarantinedErr.Reason).WithFields(logrus.Fields{
+			"quarantined": quarantinedErr.Path,
+			"user":        utils.IdentityName(c),
+			"client_ip":   c.ClientIP(),
+		}).Warn("Upload quarantined")
+	}
+	if status == http.StatusInternalServerError {
+		logger.WithError(err).Error("Failed to save file")
+	}
+	utils.SendError(c, status, message, details)
+}
+
+// setScanHeader reports the malware scan verdict of responses without a JSON body
+func setScanHeader(c *gin.Context, scan *services.ScanResult) {
+	if scan != nil {
+		c.Header("X-Scan-Verdict", scan.Verdict)
+	}
+}
+
+// uploadErrorStatus maps upload errors to a status code and message
+func uploadErrorStatus(err error) (int, string) {
+	var maxBytesErr *http.MaxBytesError
+	var quarantinedErr *services.QuarantinedError
+	switch {
+	case errors.Is(err, services.ErrInfected):
+		return http.StatusUnprocessableEntity, "File is infected; the file was quarantined"
+	case errors.Is(err, services.ErrInvalidDestination):
+		return http.StatusBadRequest, "Invalid destination"
+	case errors.Is(err, services.ErrDestinationDenied):
+		return http.StatusForbidden, "Upload destination not writable"
+	case errors.Is(err, services.ErrQuotaExceeded):
+		return http.StatusInsufficientStorage, "Storage quota exceeded"
+	case errors.Is(err, services.ErrInsufficientSpace):
+		return http.StatusInsufficientStorage, "Not enough free disk space"
+	case errors.Is(err, services.ErrScanFailed):
+		return http.StatusServiceUnavailable, "Malware scan unavailable"
+	case errors.As(err, &quarantinedErr):
+		return http.StatusUnsupportedMediaType, "File content does not match its type; the file was quarantined"
+	case errors.Is(err, services.ErrFileTooLarge), errors.As(err, &maxBytesErr):
+		return http.StatusRequestEntityTooLarge, "File too large"
+	case errors.Is(err, services.ErrExtensionNotAllowed):
+		return http.StatusBadRequest, "File type not allowed"
+	case errors.Is(err, services.ErrInvalidFilename):
+		return http.StatusBadRequest, "Invalid filename"
+	case errors.Is(err, services.ErrFileExists):
+		return http.StatusConflict, "File already exists"
+	case errors.Is(err, services.ErrChecksumMismatch):
+		return http.StatusBadRequest, "Checksum mismatch"
+	case errors.Is(err, services.ErrContentTypeNotAllowed):
+		return http.StatusUnsupportedMediaType, "File content type not allowed"
+	case errors.Is(err, services.ErrContentMismatch):
+		return http.StatusUnsupportedMediaType, "File content does not match its type"
+	default:
+		return http.StatusInternalServerError, "Failed to save file"
+	}
 }
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"net/http"
+	"simple-server/src/backend/services"
+	"simple-server/src/backend/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// AuditMiddleware records the routes listed in actions to the audit log.
+// actions maps "METHOD /route/pattern" to an audit action. It must run before
+// the auth middleware so that rejected credentials are recorded as well.
+func AuditMiddleware(audit *services.AuditService, actions map[string]string, logger *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		action, ok := actions[c.Request.Method+" "+c.FullPath()]
+		if !ok {
+			c.Next()
+			return
+		}
+
+		c.Next()
+
+		info := utils.Audit(c)
+		event := services.AuditEvent{
+			Time:     time.Now().UTC(),
+			Action:   action,
+			User:     utils.IdentityName(c),
+			Path:     info.Path,
+			Size:     info.Size,
+			ClientIP: c.ClientIP(),
+			Status:   c.Writer.Status(),
+			Outcome:  auditOutcome(c.Writer.Status()),
+			Detail:   info.Detail,
+		}
+		if identity := utils.GetIdentity(c); identity != nil {
+			event.Method = identity.Method
+		}
+		if event.Path == "" {
+			event.Path = c.Query("path")
+		}
+		if event.Path == "" {
+			event.Path = c.Param("filepath")
+		}
+		// Downloads are measured by what was actually sent
+		downloaded := action == services.AuditDownload || action == services.AuditPrivate
+		if downloaded && event.Size == 0 && event.Outcome == services.AuditSuccess && c.Writer.Size() > 0 {
+			event.Size = int64(c.Writer.Size())
+		}
+
+		if err := audit.Record(event); err != nil {
+			logger.WithError(err).Error("Failed to write audit log")
+		}
+	}
+}
+
+// auditOutcome classifies a response status
+func auditOutcome(status int) string {
+	switch {
+	case status < http.StatusBadRequest:
+		return services.AuditSuccess
+	case status == http.StatusUnauthorized, status == http.StatusForbidden:
+		return services.AuditDenied
+	default:
+		return services.AuditFailed
+	}
+}
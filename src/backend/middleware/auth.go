@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+	"simple-server/src/backend/config"
+	"simple-server/src/backend/services"
+	"simple-server/src/backend/utils"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IdentityResolver extracts the caller identity from a request, returning nil if
+// the request carries no credentials it understands
+type IdentityResolver func(c *gin.Context) *utils.Identity
+
+// AuthMiddleware resolves the caller identity and stores it in the context.
+// Resolvers are tried in order; the first one returning an identity wins.
+func AuthMiddleware(resolvers ...IdentityResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, resolve := range resolvers {
+			if identity := resolve(c); identity != nil {
+				utils.SetIdentity(c, identity)
+				break
+			}
+			// Resolvers abort on credentials that are present but invalid
+			if c.IsAborted() {
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// SessionResolver resolves identities from the signed session cookie
+func SessionResolver(cfg *config.Config, users *services.UserService, sessions *services.SessionService) IdentityResolver {
+	return func(c *gin.Context) *utils.Identity {
+		value, err := c.Cookie(cfg.Auth.CookieName)
+		if err != nil || value == "" {
+			return nil
+		}
+
+		username, err := sessions.Verify(value)
+		if err != nil {
+			return nil
+		}
+
+		// Sessions of users removed from the store stop working immediately
+		user, ok := users.GetUser(username)
+		if !ok {
+			return nil
+		}
+
+		return user.Identity("session")
+	}
+}
+
+// TokenResolver resolves identities from API tokens sent as Authorization: Bearer
+func TokenResolver(users *services.UserService, tokens *services.TokenService) IdentityResolver {
+	return func(c *gin.Context) *utils.Identity {
+		scheme, value, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			return nil
+		}
+
+		token, err := tokens.Verify(strings.TrimSpace(value))
+		if err != nil {
+			utils.SendError(c, http.StatusUnauthorized, "Invalid or expired token")
+			c.Abort()
+			return nil
+		}
+
+		// Tokens stop working when their owner is removed
+		user, ok := users.GetUser(token.Owner)
+		if !ok {
+			utils.SendError(c, http.StatusUnauthorized, "Invalid or expired token")
+			c.Abort()
+			return nil
+		}
+
+		identity := user.Identity("token")
+		identity.Admin = user.Admin && containsString(token.Scopes, services.ScopeAdmin)
+		identity.Scopes = token.Scopes
+		identity.PathPrefixes = token.PathPrefixes
+		return identity
+	}
+}
+
+// RequireScope rejects identities lacking a scope. Anonymous requests pass
+// through so that RequireAuth alone decides whether a login is needed.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity := utils.GetIdentity(c)
+		if identity != nil && !identity.HasScope(scope) {
+			utils.SendError(c, http.StatusForbidden, "Insufficient scope", "requires "+scope)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireAdmin rejects requests that are not made by an admin with the admin scope
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity := utils.GetIdentity(c)
+		if identity == nil {
+			utils.SendError(c, http.StatusUnauthorized, "Authentication required")
+			c.Abort()
+			return
+		}
+		if !identity.HasScope(services.ScopeAdmin) {
+			utils.SendError(c, http.StatusForbidden, "Admin access required")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// containsString checks if a slice contains a string
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireAuth rejects requests without an authenticated identity.
+// Browser page requests are redirected to the login page instead.
+func RequireAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if utils.GetIdentity(c) != nil {
+			c.Next()
+			return
+		}
+
+		if c.Request.Method == http.MethodGet && strings.Contains(c.GetHeader("Accept"), "text/html") {
+			c.Redirect(http.StatusFound, "/login?next="+url.QueryEscape(c.Request.URL.RequestURI()))
+			c.Abort()
+			return
+		}
+
+		utils.SendError(c, http.StatusUnauthorized, "Authentication required")
+		c.Abort()
+	}
+}
@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"simple-server/src/backend/config"
+	"simple-server/src/backend/services"
+	"simple-server/src/backend/utils"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTokenResolver(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{}
+	cfg.Storage.DataDir = t.TempDir()
+	cfg.Auth.Users = []config.UserConfig{
+		{Username: "alice", Admin: true},
+		{Username: "bob", Groups: []string{"team-a"}},
+	}
+	users, err := services.NewUserService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := services.NewTokenService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	create := func(owner string, scopes ...string) string {
+		_, plain, err := tokens.Create("test", owner, scopes, []string{"builds"}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return plain
+	}
+	bobRead := create("bob", services.ScopeRead)
+	aliceUpload := create("alice", services.ScopeUpload)
+	aliceAdmin := create("alice", services.ScopeAdmin)
+	bobAdmin := create("bob", services.ScopeAdmin)
+	ghost := create("ghost", services.ScopeRead)
+
+	router := gin.New()
+	router.Use(AuthMiddleware(TokenResolver(users, tokens)))
+	router.GET("/whoami", func(c *gin.Context) {
+		c.JSON(http.StatusOK, utils.GetIdentity(c))
+	})
+
+	tests := []struct {
+		name          string
+		authorization string
+		status        int
+		username      string
+		admin         bool
+		scopes        int
+	}{
+		{name: "no credentials", authorization: "", status: http.StatusOK},
+		{name: "other scheme", authorization: "Basic Ym9iOnB3", status: http.StatusOK},
+		{name: "user token", authorization: "Bearer " + bobRead, status: http.StatusOK, username: "bob", scopes: 1},
+		{name: "scheme is case-insensitive", authorization: "bearer " + bobRead, status: http.StatusOK, username: "bob", scopes: 1},
+		{name: "admin owner without admin scope", authorization: "Bearer " + aliceUpload, status: http.StatusOK, username: "alice", scopes: 1},
+		{name: "admin owner with admin scope", authorization: "Bearer " + aliceAdmin, status: http.StatusOK, username: "alice", admin: true, scopes: 1},
+		{name: "admin scope of a non-admin", authorization: "Bearer " + bobAdmin, status: http.StatusOK, username: "bob", scopes: 1},
+		{name: "invalid token", authorization: "Bearer ssg_nope_nope", status: http.StatusUnauthorized},
+		{name: "owner removed", authorization: "Bearer " + ghost, status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var identity *utils.Identity
+			if err := json.Unmarshal(w.Body.Bytes(), &identity); err != nil {
+				t.Fatal(err)
+			}
+			if tt.username == "" {
+				if identity != nil {
+					t.Errorf("identity = %+v, want anonymous", identity)
+				}
+				return
+			}
+			if identity == nil || identity.Username != tt.username || identity.Admin != tt.admin || len(identity.Scopes) != tt.scopes {
+				t.Fatalf("identity = %+v, want %s admin=%v", identity, tt.username, tt.admin)
+			}
+			if identity.Method != "token" || len(identity.PathPrefixes) != 1 || identity.PathPrefixes[0] != "builds" {
+				t.Errorf("identity = %+v, want a token identity limited to builds", identity)
+			}
+		})
+	}
+}
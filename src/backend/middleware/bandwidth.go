@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"simple-server/src/backend/config"
+	"simple-server/src/backend/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// throttleChunkSize is the largest write passed through at once, so that
+// connections sharing a global limit interleave smoothly
+const throttleChunkSize = 32 * 1024
+
+// BandwidthLimiter throttles response bodies per connection and globally,
+// with a separate tier for authenticated users
+type BandwidthLimiter struct {
+	config           config.BandwidthConfig
+	writeTimeout     time.Duration
+	globalDefault    *utils.TokenBucket
+	globalAuthorized *utils.TokenBucket
+}
+
+// NewBandwidthLimiter creates a limiter. Throttled responses outlast the server
+// write timeout, so it is instead applied as slack on top of the time the
+// remaining bytes take at the configured rate.
+func NewBandwidthLimiter(cfg config.BandwidthConfig, writeTimeout time.Duration) *BandwidthLimiter {
+	return &BandwidthLimiter{
+		config:           cfg,
+		writeTimeout:     writeTimeout,
+		globalDefault:    newBandwidthBucket(cfg.Default.Global),
+		globalAuthorized: newBandwidthBucket(cfg.Authenticated.Global),
+	}
+}
+
+// Middleware wraps the response writer so file downloads, including range
+// requests served by http.ServeContent, are written at the configured rate
+func (bl *BandwidthLimiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tier, global := bl.config.Default, bl.globalDefault
+		if utils.GetIdentity(c) != nil && (bl.config.Authenticated.PerConnection > 0 || bl.config.Authenticated.Global > 0) {
+			tier, global = bl.config.Authenticated, bl.globalAuthorized
+		}
+
+		var buckets []*utils.TokenBucket
+		if bucket := newBandwidthBucket(tier.PerConnection); bucket != nil {
+			buckets = append(buckets, bucket)
+		}
+		if global != nil {
+			buckets = append(buckets, global)
+		}
+		if len(buckets) == 0 {
+			c.Next()
+			return
+		}
+
+		c.Writer = &throttledWriter{
+			ResponseWriter: c.Writer,
+			ctx:            c.Request.Context(),
+			buckets:        buckets,
+			rate:           slowestRate(tier),
+			slack:          bl.writeTimeout,
+		}
+		c.Next()
+	}
+}
+
+// slowestRate returns the lowest limited rate of a tier in bytes per second
+func slowestRate(tier config.BandwidthTier) int64 {
+	rate := tier.PerConnection
+	if tier.Global > 0 && (rate <= 0 || tier.Global < rate) {
+		rate = tier.Global
+	}
+	return rate
+}
+
+// newBandwidthBucket creates a bucket holding one second worth of bytes, or nil for unlimited
+func newBandwidthBucket(bytesPerSecond int64) *utils.TokenBucket {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+
+	burst := int(bytesPerSecond)
+	if burst < throttleChunkSize {
+		burst = throttleChunkSize
+	}
+	return utils.NewTokenBucket(float64(bytesPerSecond), burst)
+}
+
+// throttledWriter waits for bandwidth before passing writes on
+type throttledWriter struct {
+	gin.ResponseWriter
+	ctx     context.Context
+	buckets []*utils.TokenBucket
+	rate    int64
+	// slack is the server write timeout; zero leaves deadlines alone
+	slack time.Duration
+}
+
+func (w *throttledWriter) Write(data []byte) (int, error) {
+	written := 0
+	for len(data) > 0 {
+		chunk := data
+		if len(chunk) > throttleChunkSize {
+			chunk = chunk[:throttleChunkSize]
+		}
+
+		for _, bucket := range w.buckets {
+			if err := bucket.WaitN(w.ctx, len(chunk)); err != nil {
+				return written, err
+			}
+		}
+		w.extendDeadline(len(data))
+
+		n, err := w.ResponseWriter.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		data = data[len(chunk):]
+	}
+	return written, nil
+}
+
+// extendDeadline moves the write deadline to when the remaining bytes should
+// have been sent at the configured rate, plus the write timeout as slack. A
+// stalled client still times out, while a slow download does not.
+func (w *throttledWriter) extendDeadline(remaining int) {
+	if w.slack <= 0 {
+		return
+	}
+	needed := time.Duration(int64(remaining) * int64(time.Second) / w.rate)
+	http.NewResponseController(w.ResponseWriter).SetWriteDeadline(time.Now().Add(needed + w.slack))
+}
+
+func (w *throttledWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *throttledWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
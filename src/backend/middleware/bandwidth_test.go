@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"simple-server/src/backend/config"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBandwidthOutlastsWriteTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const rate = 64 * 1024
+	writeTimeout := 300 * time.Millisecond
+	content := bytes.Repeat([]byte("x"), 3*rate)
+
+	limiter := NewBandwidthLimiter(config.BandwidthConfig{
+		Enabled: true,
+		Default: config.BandwidthTier{PerConnection: rate},
+	}, writeTimeout)
+	router := gin.New()
+	router.GET("/file", limiter.Middleware(), func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/octet-stream", content)
+	})
+
+	server := httptest.NewUnstartedServer(router)
+	server.Config.WriteTimeout = writeTimeout
+	server.Start()
+	defer server.Close()
+
+	start := time.Now()
+	resp, err := http.Get(server.URL + "/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("download cut off after %d bytes: %v", len(body), err)
+	}
+
+	if len(body) != len(content) {
+		t.Errorf("received %d bytes, want %d", len(body), len(content))
+	}
+	// The first second is the bucket's burst
+	if elapsed := time.Since(start); elapsed < 1500*time.Millisecond {
+		t.Errorf("download took %v, want it throttled to about 2s", elapsed)
+	}
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+	"simple-server/src/backend/services"
+	"simple-server/src/backend/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BasicAuthResolver resolves identities from HTTP Basic credentials checked against an htpasswd file
+func BasicAuthResolver(htpasswd *services.HtpasswdService, realm string) IdentityResolver {
+	return func(c *gin.Context) *utils.Identity {
+		if scheme, _, _ := strings.Cut(c.GetHeader("Authorization"), " "); !strings.EqualFold(scheme, "Basic") {
+			return nil
+		}
+
+		username, password, ok := c.Request.BasicAuth()
+		if !ok || !htpasswd.Authenticate(username, password) {
+			sendBasicChallenge(c, realm)
+			return nil
+		}
+
+		return &utils.Identity{
+			Username: username,
+			Method:   "basic",
+		}
+	}
+}
+
+// RequireBasicAuth rejects requests without an authenticated identity with a Basic challenge
+func RequireBasicAuth(realm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if utils.GetIdentity(c) != nil {
+			c.Next()
+			return
+		}
+
+		sendBasicChallenge(c, realm)
+	}
+}
+
+// sendBasicChallenge aborts the request with a 401 asking for Basic credentials
+func sendBasicChallenge(c *gin.Context, realm string) {
+	c.Header("WWW-Authenticate", "Basic realm="+strconv.Quote(realm)+`, charset="UTF-8"`)
+	utils.SendError(c, http.StatusUnauthorized, "Authentication required")
+	c.Abort()
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+	"simple-server/src/backend/services"
+	"simple-server/src/backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClientCertResolver resolves identities from verified TLS client certificates.
+// Certificates mapping to no user leave the request anonymous.
+func ClientCertResolver(certs *services.ClientCertService) IdentityResolver {
+	return func(c *gin.Context) *utils.Identity {
+		state := c.Request.TLS
+		if state == nil || len(state.VerifiedChains) == 0 {
+			return nil
+		}
+
+		return certs.Identity(state.VerifiedChains[0][0])
+	}
+}
+
+// RequireClientCert rejects requests that are not authenticated by a client certificate
+func RequireClientCert() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if identity := utils.GetIdentity(c); identity != nil && identity.Method == "certificate" {
+			c.Next()
+			return
+		}
+
+		utils.SendError(c, http.StatusUnauthorized, "Client certificate required")
+		c.Abort()
+	}
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"simple-server/src/backend/config"
+	"simple-server/src/backend/utils"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// IPFilterMiddleware rejects clients outside the allow list or inside the deny
+// list of an IP rule. Deny entries take precedence; an empty allow list allows
+// every address that is not denied.
+func IPFilterMiddleware(rule config.IPRule, logger *logrus.Logger) (gin.HandlerFunc, error) {
+	allow, err := parseCIDRs(rule.Allow)
+	if err != nil {
+		return nil, err
+	}
+	deny, err := parseCIDRs(rule.Deny)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(c *gin.Context) {
+		clientIP := c.ClientIP()
+		ip := net.ParseIP(clientIP)
+
+		allowed := ip != nil && !containsIP(deny, ip) && (len(allow) == 0 || containsIP(allow, ip))
+		if !allowed {
+			logger.WithFields(logrus.Fields{
+				"client_ip": clientIP,
+				"method":    c.Request.Method,
+				"path":      c.Request.URL.Path,
+				"user":      utils.IdentityName(c),
+			}).Warn("Request denied by IP rule")
+
+			utils.SendError(c, http.StatusForbidden, "Access denied")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}, nil
+}
+
+// parseCIDRs parses CIDR ranges; plain addresses are treated as single-host ranges
+func parseCIDRs(values []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", value)
+			}
+			if ip.To4() != nil {
+				value += "/32"
+			} else {
+				value += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", value, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// containsIP checks if any network contains the address
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"simple-server/src/backend/config"
+	"simple-server/src/backend/utils"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sweepInterval is how often buckets of inactive clients are dropped
+const sweepInterval = time.Minute
+
+// RateLimiter keeps a token bucket per client
+type RateLimiter struct {
+	rate      float64
+	burst     int
+	mu        sync.Mutex
+	buckets   map[string]*utils.TokenBucket
+	lastSweep time.Time
+}
+
+func NewRateLimiter(limit config.RateLimit) *RateLimiter {
+	burst := limit.Burst
+	if burst <= 0 {
+		burst = 1
+	}
+
+	return &RateLimiter{
+		rate:      limit.RequestsPerMinute / 60,
+		burst:     burst,
+		buckets:   make(map[string]*utils.TokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Middleware rejects requests over the limit with 429 and Retry-After. A
+// rate of zero means no limit.
+func (rl *RateLimiter) Middleware() gin.HandlerFunc {
+	if rl.rate <= 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	return func(c *gin.Context) {
+		if ok, wait := rl.bucket(clientKey(c)).Allow(); !ok {
+			sendTooManyRequests(c, wait, "Rate limit exceeded")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// bucket returns the bucket of a client, creating it if needed
+func (rl *RateLimiter) bucket(key string) *utils.TokenBucket {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(rl.lastSweep) > sweepInterval {
+		for k, b := range rl.buckets {
+			if b.Full() {
+				delete(rl.buckets, k)
+			}
+		}
+		rl.lastSweep = now
+	}
+
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = utils.NewTokenBucket(rl.rate, rl.burst)
+		rl.buckets[key] = b
+	}
+	return b
+}
+
+// ConcurrencyLimiter caps the number of simultaneous requests per client
+type ConcurrencyLimiter struct {
+	max    int
+	mu     sync.Mutex
+	active map[string]int
+}
+
+func NewConcurrencyLimiter(max int) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{
+		max:    max,
+		active: make(map[string]int),
+	}
+}
+
+// Middleware rejects requests while the client already has the maximum in flight
+func (cl *ConcurrencyLimiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := clientKey(c)
+
+		cl.mu.Lock()
+		if cl.active[key] >= cl.max {
+			cl.mu.Unlock()
+			sendTooManyRequests(c, time.Second, "Too many concurrent downloads")
+			return
+		}
+		cl.active[key]++
+		cl.mu.Unlock()
+
+		defer func() {
+			cl.mu.Lock()
+			if cl.active[key]--; cl.active[key] <= 0 {
+				delete(cl.active, key)
+			}
+			cl.mu.Unlock()
+		}()
+
+		c.Next()
+	}
+}
+
+// clientKey identifies a client by its authenticated identity, or its IP address
+func clientKey(c *gin.Context) string {
+	if identity := utils.GetIdentity(c); identity != nil {
+		return "user:" + identity.Username
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// sendTooManyRequests aborts the request with 429 and a Retry-After header in whole seconds
+func sendTooManyRequests(c *gin.Context, wait time.Duration, message string) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	utils.SendError(c, http.StatusTooManyRequests, message)
+	c.Abort()
+}
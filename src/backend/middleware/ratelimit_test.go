@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"simple-server/src/backend/config"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// request sends a GET from a client address and returns the recorded response
+func request(router *gin.Engine, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = remoteAddr
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimiter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(NewRateLimiter(config.RateLimit{RequestsPerMinute: 6, Burst: 3}).Middleware())
+	router.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for i := 0; i < 3; i++ {
+		if w := request(router, "10.0.0.1:1234"); w.Code != http.StatusOK {
+			t.Fatalf("request %d within the burst = %d", i+1, w.Code)
+		}
+	}
+	w := request(router, "10.0.0.1:1234")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("request over the burst = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	// One token every 10 seconds
+	if got := w.Header().Get("Retry-After"); got != "10" {
+		t.Errorf("Retry-After = %q, want %q", got, "10")
+	}
+
+	if w := request(router, "10.0.0.2:1234"); w.Code != http.StatusOK {
+		t.Errorf("other client limited: %d", w.Code)
+	}
+}
+
+func TestRateLimiterZeroRate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(NewRateLimiter(config.RateLimit{RequestsPerMinute: 0, Burst: 1}).Middleware())
+	router.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for i := 0; i < 100; i++ {
+		if w := request(router, "10.0.0.1:1234"); w.Code != http.StatusOK {
+			t.Fatalf("request %d with a zero rate = %d", i+1, w.Code)
+		}
+	}
+}
+
+func TestConcurrencyLimiter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	router := gin.New()
+	router.Use(NewConcurrencyLimiter(2).Middleware())
+	router.GET("/", func(c *gin.Context) {
+		entered <- struct{}{}
+		<-release
+		c.Status(http.StatusOK)
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			request(router, "10.0.0.1:1234")
+		}()
+		<-entered
+	}
+
+	if w := request(router, "10.0.0.1:1234"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("third concurrent request = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+
+	// Other clients have their own allowance
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if w := request(router, "10.0.0.2:1234"); w.Code != http.StatusOK {
+			t.Errorf("other client = %d", w.Code)
+		}
+	}()
+	<-entered
+
+	close(release)
+	wg.Wait()
+
+	go func() { <-entered }()
+	if w := request(router, "10.0.0.1:1234"); w.Code != http.StatusOK {
+		t.Errorf("request after the others finished = %d", w.Code)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"simple-server/src/backend/config"
 	"simple-server/src/backend/utils"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -28,14 +29,60 @@ func SecurityMiddleware(cfg *config.Config) gin.HandlerFunc {
 	}
 }
 
-// CORSMiddleware is a CORS middleware
-func CORSMiddleware() gin.HandlerFunc {
+// CORSRule applies a CORS policy to the URL prefixes of its route groups
+type CORSRule struct {
+	Prefixes []string
+	Policy   config.CORSPolicy
+}
+
+// CORSMiddleware is a CORS middleware. Requests are matched against the rules
+// by URL prefix, so preflight requests are answered even though no route is
+// registered for OPTIONS. Without a matching rule no CORS headers are sent and
+// browsers only allow same-origin requests.
+func CORSMiddleware(rules []CORSRule) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		policy := matchCORSRule(rules, c.Request.URL.Path)
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		c.Header("Vary", "Origin")
+
+		allowedOrigin := ""
+		if policy != nil {
+			allowedOrigin = matchOrigin(policy.AllowedOrigins, origin)
+		}
+		if allowedOrigin == "" {
+			if preflight {
+				c.AbortWithStatus(http.StatusNoContent)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		c.Header("Access-Control-Allow-Origin", allowedOrigin)
+		// Credentials are never combined with a wildcard origin
+		if policy.AllowCredentials && allowedOrigin != "*" {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
 
-		if c.Request.Method == "OPTIONS" {
+		if preflight {
+			c.Header("Access-Control-Allow-Methods", strings.Join(policy.AllowedMethods, ", "))
+			headers := strings.Join(policy.AllowedHeaders, ", ")
+			if headers == "*" {
+				headers = c.GetHeader("Access-Control-Request-Headers")
+			}
+			if headers != "" {
+				c.Header("Access-Control-Allow-Headers", headers)
+			}
+			if policy.MaxAge > 0 {
+				c.Header("Access-Control-Max-Age", strconv.Itoa(int(policy.MaxAge.Seconds())))
+			}
 			c.AbortWithStatus(http.StatusNoContent)
 			return
 		}
@@ -43,3 +90,44 @@ func CORSMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// matchCORSRule returns the policy of the first rule covering a path
+func matchCORSRule(rules []CORSRule, path string) *config.CORSPolicy {
+	for i := range rules {
+		for _, prefix := range rules[i].Prefixes {
+			if path == prefix || strings.HasPrefix(path, prefix+"/") {
+				return &rules[i].Policy
+			}
+		}
+	}
+	return nil
+}
+
+// matchOrigin returns the value for Access-Control-Allow-Origin, or "" if the
+// origin is not allowed. Patterns are exact origins, "*", or wildcard
+// subdomains such as "https://*.example.com".
+func matchOrigin(patterns []string, origin string) string {
+	for _, pattern := range patterns {
+		if pattern == "*" {
+			return "*"
+		}
+		if strings.EqualFold(pattern, origin) {
+			return origin
+		}
+
+		prefix, suffix, ok := strings.Cut(pattern, "*.")
+		if !ok {
+			continue
+		}
+		lower := strings.ToLower(origin)
+		suffix = "." + strings.ToLower(suffix)
+		// The subdomain must not overlap the prefix or the suffix
+		if len(lower) > len(prefix)+len(suffix) && strings.HasPrefix(lower, strings.ToLower(prefix)) && strings.HasSuffix(lower, suffix) {
+			sub := lower[len(prefix) : len(lower)-len(suffix)]
+			if sub != "" && !strings.ContainsAny(sub, "/:@") {
+				return origin
+			}
+		}
+	}
+	return ""
+}
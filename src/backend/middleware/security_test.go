@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"simple-server/src/backend/config"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMatchOrigin(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		origin   string
+		want     string
+	}{
+		{name: "exact", patterns: []string{"https://app.example.com"}, origin: "https://app.example.com", want: "https://app.example.com"},
+		{name: "exact is case-insensitive", patterns: []string{"https://APP.example.com"}, origin: "https://app.example.com", want: "https://app.example.com"},
+		{name: "other origin", patterns: []string{"https://app.example.com"}, origin: "https://evil.com", want: ""},
+		{name: "other scheme", patterns: []string{"https://app.example.com"}, origin: "http://app.example.com", want: ""},
+		{name: "other port", patterns: []string{"https://app.example.com"}, origin: "https://app.example.com:8443", want: ""},
+		{name: "any", patterns: []string{"*"}, origin: "https://evil.com", want: "*"},
+		{name: "later pattern", patterns: []string{"https://a.com", "https://b.com"}, origin: "https://b.com", want: "https://b.com"},
+		{name: "wildcard subdomain", patterns: []string{"https://*.example.com"}, origin: "https://app.example.com", want: "https://app.example.com"},
+		{name: "wildcard nested subdomain", patterns: []string{"https://*.example.com"}, origin: "https://a.b.example.com", want: "https://a.b.example.com"},
+		{name: "wildcard keeps origin case", patterns: []string{"https://*.example.com"}, origin: "https://App.Example.com", want: "https://App.Example.com"},
+		{name: "wildcard needs a subdomain", patterns: []string{"https://*.example.com"}, origin: "https://example.com", want: ""},
+		{name: "wildcard empty subdomain", patterns: []string{"https://*.example.com"}, origin: "https://.example.com", want: ""},
+		{name: "wildcard suffix attack", patterns: []string{"https://*.example.com"}, origin: "https://evilexample.com", want: ""},
+		{name: "wildcard parent attack", patterns: []string{"https://*.example.com"}, origin: "https://app.example.com.evil.net", want: ""},
+		{name: "wildcard scheme", patterns: []string{"https://*.example.com"}, origin: "http://app.example.com", want: ""},
+		{name: "wildcard credentials", patterns: []string{"https://*.example.com"}, origin: "https://user@evil.com/.example.com", want: ""},
+		{name: "wildcard port", patterns: []string{"https://*.example.com"}, origin: "https://evil.com:1.example.com", want: ""},
+		{name: "overlapping pattern", patterns: []string{"x.*.y"}, origin: "x.y", want: ""},
+		{name: "null origin", patterns: []string{"https://*.example.com"}, origin: "null", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchOrigin(tt.patterns, tt.origin); got != tt.want {
+				t.Errorf("matchOrigin(%q, %q) = %q, want %q", tt.patterns, tt.origin, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(CORSMiddleware([]CORSRule{
+		{Prefixes: []string{"/api"}, Policy: config.CORSPolicy{
+			AllowedOrigins:   []string{"https://*.example.com"},
+			AllowedMethods:   []string{"GET", "POST"},
+			AllowedHeaders:   []string{"Content-Type"},
+			AllowCredentials: true,
+			MaxAge:           10 * time.Minute,
+		}},
+		{Prefixes: []string{"/files"}, Policy: config.CORSPolicy{
+			AllowedOrigins:   []string{"*"},
+			AllowCredentials: true,
+		}},
+	}))
+	ok := func(c *gin.Context) { c.String(http.StatusOK, "ok") }
+	router.GET("/api/list", ok)
+	router.GET("/apix", ok)
+	router.GET("/files/a.txt", ok)
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		origin      string
+		status      int
+		allowOrigin string
+		credentials string
+		maxAge      string
+	}{
+		{name: "allowed origin", method: http.MethodGet, path: "/api/list", origin: "https://app.example.com", status: http.StatusOK, allowOrigin: "https://app.example.com", credentials: "true"},
+		{name: "refused origin still served", method: http.MethodGet, path: "/api/list", origin: "https://evil.com", status: http.StatusOK},
+		{name: "no origin", method: http.MethodGet, path: "/api/list", status: http.StatusOK},
+		{name: "prefix is a path boundary", method: http.MethodGet, path: "/apix", origin: "https://app.example.com", status: http.StatusOK},
+		{name: "wildcard without credentials", method: http.MethodGet, path: "/files/a.txt", origin: "https://evil.com", status: http.StatusOK, allowOrigin: "*"},
+		{name: "preflight", method: http.MethodOptions, path: "/api/list", origin: "https://app.example.com", status: http.StatusNoContent, allowOrigin: "https://app.example.com", credentials: "true", maxAge: "600"},
+		{name: "refused preflight", method: http.MethodOptions, path: "/api/list", origin: "https://evil.com", status: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.method == http.MethodOptions {
+				req.Header.Set("Access-Control-Request-Method", "POST")
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			header := w.Header()
+			if got := header.Get("Access-Control-Allow-Origin"); got != tt.allowOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.allowOrigin)
+			}
+			if got := header.Get("Access-Control-Allow-Credentials"); got != tt.credentials {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want %q", got, tt.credentials)
+			}
+			if got := header.Get("Access-Control-Max-Age"); got != tt.maxAge {
+				t.Errorf("Access-Control-Max-Age = %q, want %q", got, tt.maxAge)
+			}
+		})
+	}
+}
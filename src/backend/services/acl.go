@@ -0,0 +1,186 @@
+package services
+
+import (
+	"path"
+	"path/filepath"
+	"simple-server/src/backend/config"
+	"simple-server/src/backend/utils"
+	"strings"
+)
+
+// Permissions that can be granted by ACL rules
+const (
+	PermRead  = "read"
+	PermWrite = "write"
+	PermList  = "list"
+)
+
+// Principals with a special meaning in ACL rules
+const (
+	aclEveryone  = "*"
+	aclAnonymous = "anonymous"
+)
+
+// ACL evaluates the per-directory access rules from config.
+//
+// Rule paths are relative to the upload directory and matched segment by
+// segment, so "projects/*" applies to every project folder and everything
+// below it. The most specific matching path wins; if none of the rules for
+// that path name the caller, access is denied. Paths without any rule fall
+// back to the configured default.
+type ACL struct {
+	rules        []aclRule
+	defaultAllow bool
+}
+
+type aclRule struct {
+	segments    []string
+	users       []string
+	groups      []string
+	permissions map[string]bool
+}
+
+func NewACL(cfg *config.Config) *ACL {
+	acl := &ACL{
+		defaultAllow: cfg.Security.ACLDefault != "deny",
+	}
+
+	for _, r := range cfg.Security.ACL {
+		rule := aclRule{
+			segments:    splitACLPath(r.Path),
+			users:       r.Users,
+			groups:      r.Groups,
+			permissions: make(map[string]bool),
+		}
+		for _, p := range r.Permissions {
+			rule.permissions[strings.ToLower(p)] = true
+		}
+		acl.rules = append(acl.rules, rule)
+	}
+
+	return acl
+}
+
+// Allowed checks if the identity has a permission on a path relative to the upload directory
+func (a *ACL) Allowed(identity *utils.Identity, relativePath, permission string) bool {
+	segments := splitACLPath(relativePath)
+
+	if identity != nil {
+		if !identity.AllowsPath(strings.Join(segments, "/")) {
+			return false
+		}
+		if identity.Admin {
+			return true
+		}
+	}
+
+	best := -1
+	matched := false
+	granted := false
+	for _, rule := range a.rules {
+		if !rule.matches(segments) {
+			continue
+		}
+
+		if len(rule.segments) > best {
+			best = len(rule.segments)
+			matched = false
+			granted = false
+		} else if len(rule.segments) < best {
+			continue
+		}
+
+		if rule.appliesTo(identity) {
+			matched = true
+			granted = granted || rule.permissions[permission]
+		}
+	}
+
+	if best < 0 {
+		return a.defaultAllow
+	}
+	return matched && granted
+}
+
+// AllowedBelow checks if the identity has a permission somewhere below a
+// directory, so that the directory can be shown as a way in
+func (a *ACL) AllowedBelow(identity *utils.Identity, relativePath, permission string) bool {
+	if a.Allowed(identity, relativePath, permission) {
+		return true
+	}
+
+	segments := splitACLPath(relativePath)
+
+	// Identities restricted to path prefixes only see the way to those prefixes
+	if identity != nil && len(identity.PathPrefixes) > 0 {
+		dir := strings.Join(segments, "/")
+		for _, prefix := range identity.PathPrefixes {
+			if (dir == "" || strings.HasPrefix(prefix, dir+"/")) && a.AllowedBelow(identity, prefix, permission) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, rule := range a.rules {
+		if len(rule.segments) <= len(segments) || !rule.appliesTo(identity) || !rule.permissions[permission] {
+			continue
+		}
+		if matchSegments(rule.segments[:len(segments)], segments) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches checks if the rule path covers the given path
+func (r *aclRule) matches(segments []string) bool {
+	if len(r.segments) > len(segments) {
+		return false
+	}
+	return matchSegments(r.segments, segments[:len(r.segments)])
+}
+
+// appliesTo checks if the rule names the identity
+func (r *aclRule) appliesTo(identity *utils.Identity) bool {
+	for _, u := range r.users {
+		if u == aclEveryone {
+			return true
+		}
+		if identity == nil {
+			if u == aclAnonymous {
+				return true
+			}
+		} else if u == identity.Username {
+			return true
+		}
+	}
+
+	if identity != nil {
+		for _, g := range r.groups {
+			if identity.InGroup(g) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchSegments matches path segments against glob pattern segments of the same length
+func matchSegments(patterns, segments []string) bool {
+	for i, pattern := range patterns {
+		if ok, _ := path.Match(pattern, segments[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// splitACLPath splits a relative path into clean slash-separated segments
+func splitACLPath(p string) []string {
+	clean := filepath.ToSlash(utils.SanitizePath(filepath.FromSlash(p)))
+	if clean == "" {
+		return nil
+	}
+	return strings.Split(clean, "/")
+}
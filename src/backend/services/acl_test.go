@@ -0,0 +1,118 @@
+package services
+
+import (
+	"simple-server/src/backend/config"
+	"simple-server/src/backend/utils"
+	"testing"
+)
+
+func newTestACL(defaultPolicy string) *ACL {
+	return NewACL(&config.Config{
+		Security: config.SecurityConfig{
+			ACLDefault: defaultPolicy,
+			ACL: []config.ACLRule{
+				{Path: "projects/*", Users: []string{"*"}, Permissions: []string{"list"}},
+				{Path: "projects/team-a", Groups: []string{"team-a"}, Permissions: []string{"READ", "list"}},
+				{Path: "projects/team-a", Users: []string{"carol"}, Permissions: []string{"write"}},
+				{Path: "projects/team-a/secret", Users: []string{"dave"}, Permissions: []string{"read"}},
+				{Path: "public", Users: []string{"anonymous"}, Permissions: []string{"read"}},
+				{Path: "public", Users: []string{"*"}, Permissions: []string{"list"}},
+				{Path: "locked", Users: []string{"*"}, Permissions: []string{}},
+			},
+		},
+	})
+}
+
+func TestACLAllowed(t *testing.T) {
+	acl := newTestACL("allow")
+
+	bob := &utils.Identity{Username: "bob", Groups: []string{"team-a"}}
+	carol := &utils.Identity{Username: "carol"}
+	dave := &utils.Identity{Username: "dave", Groups: []string{"team-a"}}
+	admin := &utils.Identity{Username: "root", Admin: true}
+	token := &utils.Identity{Username: "bob", Groups: []string{"team-a"}, PathPrefixes: []string{"projects/team-a/docs"}}
+	adminToken := &utils.Identity{Username: "root", Admin: true, PathPrefixes: []string{"public"}}
+
+	tests := []struct {
+		name       string
+		identity   *utils.Identity
+		path       string
+		permission string
+		want       bool
+	}{
+		{name: "no rule falls back to default", identity: nil, path: "other/file.txt", permission: PermRead, want: true},
+		{name: "group grant", identity: bob, path: "projects/team-a/report.pdf", permission: PermRead, want: true},
+		{name: "permissions are case-insensitive", identity: bob, path: "projects/team-a", permission: PermRead, want: true},
+		{name: "group lacks permission", identity: bob, path: "projects/team-a", permission: PermWrite, want: false},
+		{name: "rules on the same path combine", identity: carol, path: "projects/team-a/x", permission: PermWrite, want: true},
+		{name: "same path rule for other users", identity: carol, path: "projects/team-a/x", permission: PermRead, want: false},
+		{name: "most specific path wins", identity: bob, path: "projects/team-a/secret/a.txt", permission: PermRead, want: false},
+		{name: "most specific path grants", identity: dave, path: "projects/team-a/secret/a.txt", permission: PermRead, want: true},
+		{name: "glob segment", identity: carol, path: "projects/team-b", permission: PermList, want: true},
+		{name: "glob segment without permission", identity: carol, path: "projects/team-b/a.txt", permission: PermRead, want: false},
+		{name: "glob does not match the parent", identity: nil, path: "projects", permission: PermRead, want: true},
+		{name: "anonymous principal", identity: nil, path: "public/a.txt", permission: PermRead, want: true},
+		{name: "anonymous principal excludes users", identity: bob, path: "public/a.txt", permission: PermRead, want: false},
+		{name: "everyone includes anonymous", identity: nil, path: "public", permission: PermList, want: true},
+		{name: "empty permissions deny", identity: bob, path: "locked/a.txt", permission: PermRead, want: false},
+		{name: "traversal is cleaned", identity: bob, path: "public/../locked/a.txt", permission: PermRead, want: false},
+		{name: "admin bypasses rules", identity: admin, path: "locked/a.txt", permission: PermWrite, want: true},
+		{name: "token inside prefix", identity: token, path: "projects/team-a/docs/a.txt", permission: PermRead, want: true},
+		{name: "token outside prefix", identity: token, path: "projects/team-a/report.pdf", permission: PermRead, want: false},
+		{name: "token prefix is a path boundary", identity: token, path: "projects/team-a/docs2", permission: PermRead, want: false},
+		{name: "admin token outside prefix", identity: adminToken, path: "locked", permission: PermRead, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := acl.Allowed(tt.identity, tt.path, tt.permission); got != tt.want {
+				t.Errorf("Allowed(%q, %s) = %v, want %v", tt.path, tt.permission, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestACLDefaultDeny(t *testing.T) {
+	acl := newTestACL("deny")
+
+	if acl.Allowed(nil, "other/file.txt", PermRead) {
+		t.Error("path without rules allowed with aclDefault deny")
+	}
+	if !acl.Allowed(nil, "public/a.txt", PermRead) {
+		t.Error("rule grant ignored with aclDefault deny")
+	}
+	if !acl.Allowed(&utils.Identity{Username: "root", Admin: true}, "other", PermWrite) {
+		t.Error("admin denied with aclDefault deny")
+	}
+}
+
+func TestACLAllowedBelow(t *testing.T) {
+	acl := newTestACL("deny")
+
+	bob := &utils.Identity{Username: "bob", Groups: []string{"team-a"}}
+	dave := &utils.Identity{Username: "dave"}
+	token := &utils.Identity{Username: "bob", Groups: []string{"team-a"}, PathPrefixes: []string{"projects/team-a/docs"}}
+
+	tests := []struct {
+		name     string
+		identity *utils.Identity
+		path     string
+		want     bool
+	}{
+		{name: "granted on the path", identity: bob, path: "projects/team-a", want: true},
+		{name: "granted below", identity: dave, path: "projects", want: true},
+		{name: "granted below the root", identity: nil, path: "", want: true},
+		{name: "nothing below", identity: nil, path: "other", want: false},
+		{name: "token on the way to its prefix", identity: token, path: "projects", want: true},
+		{name: "token at the root", identity: token, path: "", want: true},
+		{name: "token beside its prefix", identity: token, path: "public", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := acl.AllowedBelow(tt.identity, tt.path, PermList); got != tt.want {
+				t.Errorf("AllowedBelow(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"simple-server/src/backend/config"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// CertificateStatus describes a cached ACME certificate
+type CertificateStatus struct {
+	Domain   string
+	Issued   bool
+	NotAfter time.Time
+	RenewAt  time.Time
+}
+
+// ACMEManager obtains and renews certificates from an ACME directory.
+// Certificates and the account key are cached on disk so restarts do not
+// trigger new orders.
+type ACMEManager struct {
+	config  *config.Config
+	manager *autocert.Manager
+}
+
+func NewACMEManager(cfg *config.Config) (*ACMEManager, error) {
+	acmeCfg := cfg.Server.TLS.ACME
+
+	if len(acmeCfg.Domains) == 0 {
+		return nil, errors.New("acme: at least one domain is required")
+	}
+	if !acmeCfg.AcceptTOS {
+		return nil, errors.New("acme: acceptTOS must be set to agree to the CA's terms of service")
+	}
+	if err := os.MkdirAll(acmeCfg.CacheDir, 0700); err != nil {
+		return nil, err
+	}
+
+	client := &acme.Client{DirectoryURL: acmeCfg.DirectoryURL}
+	if acmeCfg.CABundle != "" {
+		httpClient, err := newCABundleClient(acmeCfg.CABundle)
+		if err != nil {
+			return nil, err
+		}
+		client.HTTPClient = httpClient
+	}
+
+	return &ACMEManager{
+		config: cfg,
+		manager: &autocert.Manager{
+			Prompt:      autocert.AcceptTOS,
+			Cache:       autocert.DirCache(acmeCfg.CacheDir),
+			HostPolicy:  autocert.HostWhitelist(acmeCfg.Domains...),
+			RenewBefore: acmeCfg.RenewBefore,
+			Email:       acmeCfg.Email,
+			Client:      client,
+		},
+	}, nil
+}
+
+// newCABundleClient returns an HTTP client that additionally trusts the
+// certificates in a PEM bundle
+func newCABundleClient(bundle string) (*http.Client, error) {
+	data, err := os.ReadFile(bundle)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("acme: no certificates found in %s", bundle)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	return &http.Client{Transport: transport}, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate, answering TLS-ALPN-01
+// challenges and issuing certificates on first use
+func (am *ACMEManager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return am.manager.GetCertificate(hello)
+}
+
+// TLSConfig returns a TLS config that advertises the TLS-ALPN-01 protocol
+func (am *ACMEManager) TLSConfig() *tls.Config {
+	tlsConfig := am.manager.TLSConfig()
+	tlsConfig.MinVersion = tls.VersionTLS12
+	return tlsConfig
+}
+
+// HTTPHandler answers HTTP-01 challenges and passes other requests to fallback
+func (am *ACMEManager) HTTPHandler(fallback http.Handler) http.Handler {
+	return am.manager.HTTPHandler(fallback)
+}
+
+// Status reports the cached certificate of every configured domain
+func (am *ACMEManager) Status(ctx context.Context) []CertificateStatus {
+	renewBefore := am.config.Server.TLS.ACME.RenewBefore
+
+	var statuses []CertificateStatus
+	for _, domain := range am.config.Server.TLS.ACME.Domains {
+		status := CertificateStatus{Domain: domain}
+
+		// autocert caches the key followed by the certificate chain under the domain name
+		if data, err := am.manager.Cache.Get(ctx, domain); err == nil {
+			if leaf := firstCertificate(data); leaf != nil {
+				status.Issued = true
+				status.NotAfter = leaf.NotAfter
+				status.RenewAt = leaf.NotAfter.Add(-renewBefore)
+			}
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
+
+// firstCertificate parses the first certificate of a PEM bundle
+func firstCertificate(data []byte) *x509.Certificate {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil
+		}
+		if block.Type == "CERTIFICATE" {
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil
+			}
+			return cert
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"simple-server/src/backend/config"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Audit actions
+const (
+	AuditUpload   = "upload"
+	AuditDownload = "download"
+	AuditList     = "list"
+	AuditSearch   = "search"
+	AuditPrivate  = "private"
+	AuditModerate = "moderate"
+	AuditDelete   = "delete"
+)
+
+// Audit outcomes
+const (
+	AuditSuccess = "success"
+	AuditDenied  = "denied"
+	AuditFailed  = "failed"
+)
+
+// AuditEvent is one line of the audit log
+type AuditEvent struct {
+	Time     time.Time `json:"time"`
+	Action   string    `json:"action"`
+	User     string    `json:"user"`
+	Method   string    `json:"method,omitempty"`
+	Path     string    `json:"path,omitempty"`
+	Size     int64     `json:"size,omitempty"`
+	ClientIP string    `json:"clientIP"`
+	Status   int       `json:"status"`
+	Outcome  string    `json:"outcome"`
+	Detail   string    `json:"detail,omitempty"`
+}
+
+// AuditFilter selects audit events; zero values match everything
+type AuditFilter struct {
+	User       string
+	PathPrefix string
+	Action     string
+	Since      time.Time
+	Until      time.Time
+	Limit      int
+}
+
+// AuditService appends audit events as JSON lines and rotates the file by size.
+// Rotated files are kept as audit.log.1 (newest) up to audit.log.N.
+type AuditService struct {
+	config *config.Config
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+}
+
+func NewAuditService(cfg *config.Config) (*AuditService, error) {
+	as := &AuditService{
+		config: cfg,
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cfg.Audit.File), 0755); err != nil {
+		return nil, err
+	}
+	if err := as.openLocked(); err != nil {
+		return nil, err
+	}
+
+	return as, nil
+}
+
+// Record appends an event to the audit log
+func (as *AuditService) Record(event AuditEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	// A failed rotation is reported, but the event is still written
+	var rotateErr error
+	maxSize := int64(as.config.Audit.MaxSizeMB) * 1024 * 1024
+	if maxSize > 0 && as.size > 0 && as.size+int64(len(line)) > maxSize {
+		rotateErr = as.rotateLocked()
+	}
+
+	n, err := as.file.Write(line)
+	as.size += int64(n)
+	if err != nil {
+		return err
+	}
+	return rotateErr
+}
+
+// Query returns the events matching a filter, oldest first. With a limit the
+// most recent matching events are returned; the files are read backwards from
+// the newest line, so reading stops once the limit is reached. The files are
+// opened under the lock, so a concurrent rotation cannot swap them, and read
+// without it.
+func (as *AuditService) Query(filter AuditFilter) ([]AuditEvent, error) {
+	files, err := as.openAll()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	events := []AuditEvent{}
+	full := false
+	for _, f := range files {
+		err := scanLinesBackward(f, func(line []byte) bool {
+			var event AuditEvent
+			if err := json.Unmarshal(line, &event); err != nil {
+				return true
+			}
+			if filter.matches(event) {
+				events = append(events, event)
+				full = filter.Limit > 0 && len(events) >= filter.Limit
+			}
+			return !full
+		})
+		if err != nil {
+			return nil, err
+		}
+		if full {
+			break
+		}
+	}
+
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	return events, nil
+}
+
+// scanLinesBackward calls fn with each non-empty line of a file, last line
+// first, until fn returns false. Lines are only valid during the call.
+func scanLinesBackward(f *os.File, fn func(line []byte) bool) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, 64*1024)
+	var partial []byte
+	for offset := info.Size(); offset > 0; {
+		n := int64(len(buf))
+		if offset < n {
+			n = offset
+		}
+		offset -= n
+		if _, err := f.ReadAt(buf[:n], offset); err != nil {
+			return err
+		}
+
+		// The start of the chunk may belong to a line continuing in the next one
+		chunk := append(buf[:n:n], partial...)
+		for {
+			i := bytes.LastIndexByte(chunk, '\n')
+			if i < 0 {
+				break
+			}
+			if line := chunk[i+1:]; len(line) > 0 && !fn(line) {
+				return nil
+			}
+			chunk = chunk[:i]
+		}
+		partial = append(partial[:0], chunk...)
+	}
+
+	if len(partial) > 0 {
+		fn(partial)
+	}
+	return nil
+}
+
+// openAll opens the active file and the rotated files, newest first
+func (as *AuditService) openAll() ([]*os.File, error) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	var files []*os.File
+	for i := 0; i <= as.config.Audit.MaxBackups; i++ {
+		f, err := os.Open(as.backupName(i))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			for _, opened := range files {
+				opened.Close()
+			}
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// Close closes the audit log file
+func (as *AuditService) Close() error {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	return as.file.Close()
+}
+
+// matches checks if an event passes the filter
+func (f AuditFilter) matches(event AuditEvent) bool {
+	if f.User != "" && event.User != f.User {
+		return false
+	}
+	if f.Action != "" && event.Action != f.Action {
+		return false
+	}
+	if f.PathPrefix != "" && !strings.HasPrefix(event.Path, f.PathPrefix) {
+		return false
+	}
+	if !f.Since.IsZero() && event.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && event.Time.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// backupName returns the name of the i-th rotated file; 0 is the active file
+func (as *AuditService) backupName(i int) string {
+	if i == 0 {
+		return as.config.Audit.File
+	}
+	return fmt.Sprintf("%s.%d", as.config.Audit.File, i)
+}
+
+// openLocked opens the active file for appending. Callers must hold as.mu.
+func (as *AuditService) openLocked() error {
+	f, err := os.OpenFile(as.config.Audit.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	as.file = f
+	as.size = info.Size()
+	return nil
+}
+
+// rotateLocked shifts the rotated files and starts a new active file.
+// Callers must hold as.mu.
+func (as *AuditService) rotateLocked() error {
+	as.file.Close()
+
+	// Keep appending to the active file if it cannot be moved away
+	var renameErr error
+	maxBackups := as.config.Audit.MaxBackups
+	if maxBackups > 0 {
+		os.Remove(as.backupName(maxBackups))
+		for i := maxBackups - 1; i >= 0; i-- {
+			if err := os.Rename(as.backupName(i), as.backupName(i+1)); err != nil && !os.IsNotExist(err) && renameErr == nil {
+				renameErr = err
+			}
+		}
+	} else {
+		os.Remove(as.backupName(0))
+	}
+
+	if err := as.openLocked(); err != nil {
+		return err
+	}
+	return renameErr
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"simple-server/src/backend/config"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditQuery(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Audit.File = filepath.Join(t.TempDir(), "audit.log")
+	cfg.Audit.MaxBackups = 2
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	event := func(i int) AuditEvent {
+		e := AuditEvent{Time: start.Add(time.Duration(i) * time.Minute), Action: AuditDownload, User: "bob", Path: "/docs/a.txt", Status: 200}
+		if i%2 == 1 {
+			e.Action, e.User, e.Path = AuditUpload, "alice", "/incoming/b.txt"
+		}
+		// Some lines are longer than the read buffer
+		if i%10 == 0 {
+			e.Detail = strings.Repeat("x", 100*1024)
+		}
+		return e
+	}
+
+	// Events 0-49 are in the rotated file, the rest are recorded live
+	var rotated []byte
+	for i := 0; i < 50; i++ {
+		line, _ := json.Marshal(event(i))
+		rotated = append(append(rotated, line...), '\n')
+	}
+	rotated = append(rotated, "not json\n\n"...)
+	if err := os.WriteFile(cfg.Audit.File+".1", rotated, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	as, err := NewAuditService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer as.Close()
+	for i := 50; i < 100; i++ {
+		if err := as.Record(event(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter AuditFilter
+		first  int
+		last   int
+		count  int
+	}{
+		{name: "all", filter: AuditFilter{}, first: 0, last: 99, count: 100},
+		{name: "limit within the active file", filter: AuditFilter{Limit: 5}, first: 95, last: 99, count: 5},
+		{name: "limit across files", filter: AuditFilter{Limit: 60}, first: 40, last: 99, count: 60},
+		{name: "limit with a filter", filter: AuditFilter{User: "alice", Limit: 30}, first: 41, last: 99, count: 30},
+		{name: "path prefix", filter: AuditFilter{PathPrefix: "/docs/", Limit: 3}, first: 94, last: 98, count: 3},
+		{name: "time range", filter: AuditFilter{Since: start.Add(10 * time.Minute), Until: start.Add(19 * time.Minute)}, first: 10, last: 19, count: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := as.Query(tt.filter)
+			if err != nil {
+				t.Fatalf("Query: %v", err)
+			}
+			if len(events) != tt.count {
+				t.Fatalf("Query returned %d events, want %d", len(events), tt.count)
+			}
+			if !events[0].Time.Equal(event(tt.first).Time) || !events[len(events)-1].Time.Equal(event(tt.last).Time) {
+				t.Errorf("Query returned %v to %v, want events %d to %d", events[0].Time, events[len(events)-1].Time, tt.first, tt.last)
+			}
+			for i := 1; i < len(events); i++ {
+				if events[i].Time.Before(events[i-1].Time) {
+					t.Fatalf("events are not oldest first at %d", i)
+				}
+			}
+			if events[0].Detail != event(tt.first).Detail || events[len(events)-1].Detail != event(tt.last).Detail {
+				t.Error("long line was not read whole")
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"simple-server/src/backend/config"
+	"sync"
+	"time"
+)
+
+const (
+	// certCheckInterval limits how often certificate files are checked for changes
+	certCheckInterval = 5 * time.Second
+	// selfSignedValidity is the lifetime of generated certificates
+	selfSignedValidity = 365 * 24 * time.Hour
+	// selfSignedRenewBefore regenerates self-signed certificates this long before they expire
+	selfSignedRenewBefore = 30 * 24 * time.Hour
+)
+
+// CertificateManager serves the TLS certificate from disk and reloads it when
+// the files change. Without configured files it generates and persists a
+// self-signed certificate for LAN use.
+type CertificateManager struct {
+	config     *config.Config
+	certFile   string
+	keyFile    string
+	selfSigned bool
+	mu         sync.Mutex
+	cert       *tls.Certificate
+	modTime    time.Time
+	lastCheck  time.Time
+}
+
+func NewCertificateManager(cfg *config.Config) (*CertificateManager, error) {
+	cm := &CertificateManager{
+		config:   cfg,
+		certFile: cfg.Server.TLS.CertFile,
+		keyFile:  cfg.Server.TLS.KeyFile,
+	}
+
+	if cm.certFile == "" || cm.keyFile == "" {
+		cm.selfSigned = true
+		cm.certFile = filepath.Join(cfg.Storage.DataDir, "tls", "self-signed.crt")
+		cm.keyFile = filepath.Join(cfg.Storage.DataDir, "tls", "self-signed.key")
+
+		if err := cm.ensureSelfSigned(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cm.load(); err != nil {
+		return nil, err
+	}
+
+	return cm, nil
+}
+
+// SelfSigned reports whether a generated certificate is in use
+func (cm *CertificateManager) SelfSigned() bool {
+	return cm.selfSigned
+}
+
+// CertFile returns the path of the certificate in use
+func (cm *CertificateManager) CertFile() string {
+	return cm.certFile
+}
+
+// Certificate returns the loaded certificate
+func (cm *CertificateManager) Certificate() *tls.Certificate {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	return cm.cert
+}
+
+// GetCertificate implements tls.Config.GetCertificate
+func (cm *CertificateManager) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(cm.lastCheck) >= certCheckInterval {
+		cm.lastCheck = now
+
+		// A failed renewal keeps serving the current certificate until the next check
+		if cm.selfSigned && cm.cert.Leaf != nil && now.Add(selfSignedRenewBefore).After(cm.cert.Leaf.NotAfter) {
+			cm.generateSelfSigned()
+		}
+
+		// A broken or half-written certificate keeps the previous one in use
+		if info, err := os.Stat(cm.certFile); err == nil && !info.ModTime().Equal(cm.modTime) {
+			cm.loadLocked()
+		}
+	}
+
+	return cm.cert, nil
+}
+
+// load reads the certificate and key files
+func (cm *CertificateManager) load() error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	return cm.loadLocked()
+}
+
+// loadLocked reads the certificate and key files. Callers must hold cm.mu.
+func (cm *CertificateManager) loadLocked() error {
+	info, err := os.Stat(cm.certFile)
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(cm.certFile, cm.keyFile)
+	if err != nil {
+		return err
+	}
+	if cert.Leaf == nil && len(cert.Certificate) > 0 {
+		cert.Leaf, _ = x509.ParseCertificate(cert.Certificate[0])
+	}
+
+	cm.cert = &cert
+	cm.modTime = info.ModTime()
+	return nil
+}
+
+// ensureSelfSigned generates a self-signed certificate unless a valid one exists
+func (cm *CertificateManager) ensureSelfSigned() error {
+	cert, err := tls.LoadX509KeyPair(cm.certFile, cm.keyFile)
+	if err == nil {
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err == nil && time.Now().Add(selfSignedRenewBefore).Before(leaf.NotAfter) {
+			return nil
+		}
+	}
+
+	return cm.generateSelfSigned()
+}
+
+// generateSelfSigned writes a new self-signed certificate covering localhost,
+// the host name, local addresses and the configured host names
+func (cm *CertificateManager) generateSelfSigned() error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "StreamFile Server", Organization: []string{"StreamFile Server (self-signed)"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+
+	hosts := append([]string{"localhost"}, cm.config.Server.TLS.Hosts...)
+	if hostname, err := os.Hostname(); err == nil {
+		hosts = append(hosts, hostname)
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	template.IPAddresses = append(template.IPAddresses, net.IPv4(127, 0, 0, 1), net.IPv6loopback)
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() {
+				template.IPAddresses = append(template.IPAddresses, ipNet.IP)
+			}
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cm.certFile), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(cm.keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return err
+	}
+	return os.WriteFile(cm.certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+}
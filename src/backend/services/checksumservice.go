@@ -0,0 +1,225 @@
+package services
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"simple-server/src/backend/config"
+	"simple-server/src/backend/utils"
+	"sync"
+	"time"
+)
+
+// checksumEntry is the stored digest of a file. Size and modification time
+// tell whether the file changed since it was hashed.
+type checksumEntry struct {
+	SHA256  string    `json:"sha256"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// checksumRecord is one line of the journal
+type checksumRecord struct {
+	Path string `json:"path"`
+	checksumEntry
+}
+
+// maxConcurrentHashes caps the files hashed on demand at the same time
+const maxConcurrentHashes = 2
+
+// ErrChecksumBusy is returned when too many files are being hashed on demand
+var ErrChecksumBusy = errors.New("too many checksums in progress")
+
+// ChecksumService keeps the SHA-256 digests of stored files, keyed by absolute path.
+// New digests are appended to a journal, which is folded into the state file
+// on startup and whenever it outgrows the state.
+type ChecksumService struct {
+	config      *config.Config
+	stateFile   string
+	journalFile string
+	hashing     chan struct{}
+	mu          sync.Mutex
+	entries     map[string]*checksumEntry
+	journal     *os.File
+	journalSize int
+}
+
+func NewChecksumService(cfg *config.Config) (*ChecksumService, error) {
+	cs := &ChecksumService{
+		config:      cfg,
+		stateFile:   filepath.Join(cfg.Storage.DataDir, "checksums.json"),
+		journalFile: filepath.Join(cfg.Storage.DataDir, "checksums.journal"),
+		hashing:     make(chan struct{}, maxConcurrentHashes),
+		entries:     make(map[string]*checksumEntry),
+	}
+
+	if err := utils.ReadJSONFile(cs.stateFile, &cs.entries); err != nil {
+		return nil, err
+	}
+	if err := cs.replayJournal(); err != nil {
+		return nil, err
+	}
+
+	// Forget files that were removed while the server was down
+	for path := range cs.entries {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			delete(cs.entries, path)
+		}
+	}
+	if err := cs.compactLocked(); err != nil {
+		return nil, err
+	}
+
+	return cs, nil
+}
+
+// replayJournal applies the digests recorded since the state file was written.
+// A torn last line from a crash is ignored.
+func (cs *ChecksumService) replayJournal() error {
+	f, err := os.Open(cs.journalFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record checksumRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil || record.Path == "" {
+			continue
+		}
+		entry := record.checksumEntry
+		cs.entries[record.Path] = &entry
+	}
+	return scanner.Err()
+}
+
+// compactLocked writes all digests to the state file and starts an empty
+// journal. Callers must hold cs.mu, or own cs exclusively.
+func (cs *ChecksumService) compactLocked() error {
+	if err := utils.WriteJSONFile(cs.stateFile, cs.entries); err != nil {
+		return err
+	}
+
+	if cs.journal != nil {
+		cs.journal.Close()
+	}
+	f, err := os.OpenFile(cs.journalFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0600)
+	if err != nil {
+		cs.journal = nil
+		return err
+	}
+	cs.journal = f
+	cs.journalSize = 0
+	return nil
+}
+
+// Track records the digest computed while an upload was stored
+func (cs *ChecksumService) Track(file *StoredFile, req UploadRequest) {
+	if file.SHA256 == "" {
+		return
+	}
+	info, err := os.Stat(file.Path)
+	if err != nil {
+		return
+	}
+
+	cs.record(file.Path, file.SHA256, info)
+}
+
+// Lookup returns the stored digest of a file if the file has not changed since it was hashed
+func (cs *ChecksumService) Lookup(fullPath string) (string, bool) {
+	key, err := filepath.Abs(fullPath)
+	if err != nil {
+		return "", false
+	}
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return "", false
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	entry, ok := cs.entries[key]
+	if !ok || entry.Size != info.Size() || !entry.ModTime.Equal(info.ModTime()) {
+		return "", false
+	}
+	return entry.SHA256, true
+}
+
+// Sum returns the digest of a file, hashing and recording it if no current
+// digest is stored. Returns ErrChecksumBusy rather than queueing when the
+// maximum number of files is already being hashed.
+func (cs *ChecksumService) Sum(fullPath string) (string, error) {
+	if sum, ok := cs.Lookup(fullPath); ok {
+		return sum, nil
+	}
+
+	select {
+	case cs.hashing <- struct{}{}:
+		defer func() { <-cs.hashing }()
+	default:
+		return "", ErrChecksumBusy
+	}
+
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+
+	cs.record(fullPath, sum, info)
+	return sum, nil
+}
+
+// record stores a digest. Persisting is best effort; a lost entry is rehashed on demand.
+func (cs *ChecksumService) record(fullPath, sum string, info os.FileInfo) {
+	key, err := filepath.Abs(fullPath)
+	if err != nil {
+		return
+	}
+
+	entry := checksumEntry{
+		SHA256:  sum,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}
+	line, err := json.Marshal(checksumRecord{Path: key, checksumEntry: entry})
+	if err != nil {
+		return
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.entries[key] = &entry
+
+	// Rewrite the state once the journal holds more lines than there are
+	// digests, so each record costs constant time on average
+	if cs.journal == nil || cs.journalSize >= len(cs.entries) {
+		cs.compactLocked()
+		return
+	}
+	if _, err := cs.journal.Write(append(line, '\n')); err == nil {
+		cs.journalSize++
+	}
+}
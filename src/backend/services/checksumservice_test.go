@@ -0,0 +1,118 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"simple-server/src/backend/config"
+	"testing"
+)
+
+func TestChecksumJournal(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Storage.DataDir = t.TempDir()
+	dir := t.TempDir()
+
+	cs, err := NewChecksumService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	for i := 0; i < 10; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("file%d.txt", i))
+		if err := os.WriteFile(path, []byte(path), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := cs.Sum(path); err != nil {
+			t.Fatalf("Sum: %v", err)
+		}
+		paths = append(paths, path)
+	}
+
+	// The journal is folded in before it outgrows the digests
+	if lines := countLines(t, cs.journalFile); lines == 0 || lines > len(paths) {
+		t.Errorf("journal has %d lines for %d digests", lines, len(paths))
+	}
+
+	// A torn line from a crash is skipped
+	f, err := os.OpenFile(cs.journalFile, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"path":"/torn`)
+	f.Close()
+	os.Remove(paths[0])
+
+	restarted, err := NewChecksumService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths[1:] {
+		want, _ := cs.Lookup(path)
+		if got, ok := restarted.Lookup(path); !ok || got != want {
+			t.Errorf("Lookup(%s) after restart = %q, %v, want %q", filepath.Base(path), got, ok, want)
+		}
+	}
+	if _, ok := restarted.entries[paths[0]]; ok {
+		t.Error("removed file is still recorded after restart")
+	}
+	if lines := countLines(t, restarted.journalFile); lines != 0 {
+		t.Errorf("journal has %d lines after restart, want 0", lines)
+	}
+}
+
+func TestChecksumSumBusy(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Storage.DataDir = t.TempDir()
+	cs, err := NewChecksumService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hashed := filepath.Join(t.TempDir(), "hashed.txt")
+	unhashed := filepath.Join(t.TempDir(), "unhashed.txt")
+	for _, path := range []string{hashed, unhashed} {
+		if err := os.WriteFile(path, []byte(path), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := cs.Sum(hashed); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < maxConcurrentHashes; i++ {
+		cs.hashing <- struct{}{}
+	}
+	if _, err := cs.Sum(unhashed); !errors.Is(err, ErrChecksumBusy) {
+		t.Errorf("Sum while busy error = %v, want %v", err, ErrChecksumBusy)
+	}
+	// Stored digests are still answered
+	if _, err := cs.Sum(hashed); err != nil {
+		t.Errorf("Sum of a stored digest while busy: %v", err)
+	}
+
+	<-cs.hashing
+	if _, err := cs.Sum(unhashed); err != nil {
+		t.Errorf("Sum after a slot freed: %v", err)
+	}
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines++
+	}
+	return lines
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"simple-server/src/backend/config"
+	"simple-server/src/backend/utils"
+	"strings"
+)
+
+// ClientCertService maps client certificates to identities. Certificates are
+// verified against CAPool by the TLS handshake before they reach the service.
+type ClientCertService struct {
+	config *config.Config
+	users  *UserService
+	pool   *x509.CertPool
+}
+
+func NewClientCertService(cfg *config.Config, users *UserService) (*ClientCertService, error) {
+	if !cfg.Server.TLS.Enabled {
+		return nil, errors.New("client certificates require server.tls.enabled")
+	}
+
+	switch cfg.Security.ClientCerts.UsernameFrom {
+	case "", "commonName", "email", "dnsName":
+	default:
+		return nil, fmt.Errorf("invalid usernameFrom %q", cfg.Security.ClientCerts.UsernameFrom)
+	}
+
+	data, err := os.ReadFile(cfg.Security.ClientCerts.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", cfg.Security.ClientCerts.CAFile)
+	}
+
+	return &ClientCertService{
+		config: cfg,
+		users:  users,
+		pool:   pool,
+	}, nil
+}
+
+// CAPool returns the CAs client certificates must chain to
+func (cs *ClientCertService) CAPool() *x509.CertPool {
+	return cs.pool
+}
+
+// Identity maps a verified client certificate to an identity, or returns nil
+// if the certificate matches no user
+func (cs *ClientCertService) Identity(cert *x509.Certificate) *utils.Identity {
+	username := cs.Username(cert)
+	if username == "" {
+		return nil
+	}
+
+	// Known users keep their groups and admin flag
+	if user, ok := cs.users.GetUser(username); ok {
+		return user.Identity("certificate")
+	}
+
+	return &utils.Identity{
+		Username: username,
+		Method:   "certificate",
+	}
+}
+
+// Username returns the user name a certificate maps to. Explicit mappings
+// take precedence over the usernameFrom field.
+func (cs *ClientCertService) Username(cert *x509.Certificate) string {
+	subject := cert.Subject.String()
+	sans := certificateSANs(cert)
+
+	for _, m := range cs.config.Security.ClientCerts.Mappings {
+		if m.Subject != "" && (m.Subject == subject || m.Subject == cert.Subject.CommonName) {
+			return m.Username
+		}
+		if m.SAN != "" && containsFold(sans, m.SAN) {
+			return m.Username
+		}
+	}
+
+	switch cs.config.Security.ClientCerts.UsernameFrom {
+	case "commonName":
+		return cert.Subject.CommonName
+	case "email":
+		if len(cert.EmailAddresses) > 0 {
+			return cert.EmailAddresses[0]
+		}
+	case "dnsName":
+		if len(cert.DNSNames) > 0 {
+			return cert.DNSNames[0]
+		}
+	}
+
+	return ""
+}
+
+// certificateSANs lists the subject alternative names of a certificate
+func certificateSANs(cert *x509.Certificate) []string {
+	var sans []string
+	sans = append(sans, cert.DNSNames...)
+	sans = append(sans, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+	return sans
+}
+
+// containsFold checks if a slice contains a string, ignoring case
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
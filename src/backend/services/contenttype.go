@@ -0,0 +1,84 @@
+package services
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// sniffLen is the number of leading bytes used to detect the content type
+const sniffLen = 3072
+
+// checkContent detects the type of an upload from its first bytes. It returns
+// ErrContentTypeNotAllowed if the type is not in the allowed list and
+// ErrContentMismatch if it contradicts the extension. Empty files are not checked.
+func checkContent(head []byte, ext string, allowed []string) error {
+	if len(head) == 0 {
+		return nil
+	}
+
+	detected := mimetype.Detect(head)
+	if !isAllowedMimeType(detected, allowed) {
+		return fmt.Errorf("%w: %s", ErrContentTypeNotAllowed, detected)
+	}
+	if !contentMatchesExtension(detected, ext) {
+		return fmt.Errorf("%w: %s is not %s", ErrContentMismatch, detected, ext)
+	}
+	return nil
+}
+
+// isAllowedMimeType checks the detected type against patterns such as
+// "image/png" or "image/*"
+func isAllowedMimeType(detected *mimetype.MIME, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	detectedType, _, _ := mime.ParseMediaType(detected.String())
+	for _, pattern := range allowed {
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+			if strings.HasPrefix(detectedType, strings.ToLower(prefix)+"/") {
+				return true
+			}
+		} else if detected.Is(strings.ToLower(pattern)) {
+			return true
+		}
+	}
+	return false
+}
+
+// contentMatchesExtension checks whether the detected type is one the
+// extension may hold. Extensions without a known type match anything, and so
+// do formats contained in others, like a .zip holding a .docx.
+func contentMatchesExtension(detected *mimetype.MIME, ext string) bool {
+	declared, _, _ := mime.ParseMediaType(mime.TypeByExtension(strings.ToLower(ext)))
+	if declared == "" {
+		return true
+	}
+
+	for m := detected; m != nil; m = m.Parent() {
+		if m.Is(declared) || strings.EqualFold(m.Extension(), ext) {
+			return true
+		}
+	}
+
+	// Text formats are only told apart by heuristics, so text content is
+	// only held against extensions of binary formats that can be recognized
+	if isTextMIME(detected) {
+		known := mimetype.Lookup(declared)
+		return known == nil || isTextMIME(known)
+	}
+	return false
+}
+
+// isTextMIME checks if a type is text/plain or derived from it
+func isTextMIME(m *mimetype.MIME) bool {
+	for ; m != nil; m = m.Parent() {
+		if m.Is("text/plain") {
+			return true
+		}
+	}
+	return false
+}
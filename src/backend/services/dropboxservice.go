@@ -0,0 +1,258 @@
+package services
+
+import (
+	"crypto/subtle"
+	"errors"
+	"path/filepath"
+	"simple-server/src/backend/config"
+	"simple-server/src/backend/utils"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrDropBoxNotFound  = errors.New("drop box not found")
+	ErrDropBoxExpired   = errors.New("drop box expired")
+	ErrDropBoxQuota     = errors.New("drop box byte quota exceeded")
+	ErrDropBoxFileLimit = errors.New("drop box file limit reached")
+)
+
+// DropBox is an upload-only link into a dedicated folder of the incoming directory
+type DropBox struct {
+	ID                string    `json:"id"`
+	Hash              string    `json:"hash,omitempty"`
+	Label             string    `json:"label"`
+	Folder            string    `json:"folder"`
+	MaxBytes          int64     `json:"maxBytes,omitempty"`
+	MaxFiles          int       `json:"maxFiles,omitempty"`
+	AllowedExtensions []string  `json:"allowedExtensions,omitempty"`
+	CreatedBy         string    `json:"createdBy"`
+	CreatedAt         time.Time `json:"createdAt"`
+	ExpiresAt         time.Time `json:"expiresAt"`
+	UsedBytes         int64     `json:"usedBytes"`
+	UsedFiles         int       `json:"usedFiles"`
+}
+
+// DropBoxRequest holds the settings of a new drop box
+type DropBoxRequest struct {
+	Label             string
+	Folder            string
+	MaxBytes          int64
+	MaxFiles          int
+	AllowedExtensions []string
+	TTL               time.Duration
+}
+
+type DropBoxService struct {
+	config    *config.Config
+	stateFile string
+	mu        sync.Mutex
+	boxes     map[string]*DropBox
+}
+
+func NewDropBoxService(cfg *config.Config) (*DropBoxService, error) {
+	ds := &DropBoxService{
+		config:    cfg,
+		stateFile: filepath.Join(cfg.Storage.DataDir, "dropboxes.json"),
+		boxes:     make(map[string]*DropBox),
+	}
+
+	var boxes []*DropBox
+	if err := utils.ReadJSONFile(ds.stateFile, &boxes); err != nil {
+		return nil, err
+	}
+	for _, box := range boxes {
+		ds.boxes[box.ID] = box
+	}
+
+	return ds, nil
+}
+
+// Create sets up a new drop box and returns its secret token
+func (ds *DropBoxService) Create(req DropBoxRequest, createdBy string) (*DropBox, string, error) {
+	id, err := utils.RandomToken(6)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := utils.RandomToken(16)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Each drop box gets a single folder directly below the incoming directory
+	folder := strings.ReplaceAll(utils.SanitizePath(req.Folder), string(filepath.Separator), "-")
+	if folder == "" || utils.IsHiddenFile(folder) {
+		folder = "dropbox-" + id
+	}
+
+	ttl := req.TTL
+	if ttl <= 0 {
+		ttl = ds.config.DropBox.DefaultTTL
+	}
+
+	// An empty list means the global default, not "anything goes"
+	extensions := req.AllowedExtensions
+	if len(extensions) == 0 {
+		extensions = nil
+	}
+
+	now := time.Now()
+	box := &DropBox{
+		ID:                id,
+		Hash:              hashToken(secret),
+		Label:             req.Label,
+		Folder:            folder,
+		MaxBytes:          req.MaxBytes,
+		MaxFiles:          req.MaxFiles,
+		AllowedExtensions: extensions,
+		CreatedBy:         createdBy,
+		CreatedAt:         now,
+		ExpiresAt:         now.Add(ttl),
+	}
+
+	ds.mu.Lock()
+	ds.boxes[id] = box
+	err = ds.saveLocked()
+	ds.mu.Unlock()
+	if err != nil {
+		return nil, "", err
+	}
+
+	snapshot := *box
+	snapshot.Hash = ""
+	return &snapshot, id + "_" + secret, nil
+}
+
+// Resolve looks up the drop box of a token
+func (ds *DropBoxService) Resolve(token string) (*DropBox, error) {
+	id, secret, ok := strings.Cut(token, "_")
+	if !ok {
+		return nil, ErrDropBoxNotFound
+	}
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	box, ok := ds.boxes[id]
+	if !ok || subtle.ConstantTimeCompare([]byte(box.Hash), []byte(hashToken(secret))) != 1 {
+		return nil, ErrDropBoxNotFound
+	}
+	if time.Now().After(box.ExpiresAt) {
+		return nil, ErrDropBoxExpired
+	}
+
+	snapshot := *box
+	snapshot.Hash = ""
+	return &snapshot, nil
+}
+
+// Dir returns the directory uploads of a drop box are written to
+func (ds *DropBoxService) Dir(box *DropBox) string {
+	return filepath.Join(ds.config.Storage.IncomingDir, box.Folder)
+}
+
+// DropBoxUploader is the uploader name of files sent through a drop box, so
+// that quotas and moderation attribute them to the box
+func DropBoxUploader(id string) string {
+	return "dropbox:" + id
+}
+
+// Reserve claims quota for an upload of the given size before it is written
+func (ds *DropBoxService) Reserve(id string, size int64) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	box, ok := ds.boxes[id]
+	if !ok {
+		return ErrDropBoxNotFound
+	}
+	if time.Now().After(box.ExpiresAt) {
+		return ErrDropBoxExpired
+	}
+	if box.MaxFiles > 0 && box.UsedFiles >= box.MaxFiles {
+		return ErrDropBoxFileLimit
+	}
+	if box.MaxBytes > 0 && box.UsedBytes+size > box.MaxBytes {
+		return ErrDropBoxQuota
+	}
+
+	box.UsedBytes += size
+	box.UsedFiles++
+	return ds.saveLocked()
+}
+
+// Release returns quota claimed by Reserve, e.g. after a failed upload
+func (ds *DropBoxService) Release(id string, size int64) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	box, ok := ds.boxes[id]
+	if !ok {
+		return
+	}
+	box.UsedBytes -= size
+	box.UsedFiles--
+	ds.saveLocked()
+}
+
+// Settle corrects the bytes claimed by Reserve to the size actually stored
+func (ds *DropBoxService) Settle(id string, reserved, stored int64) {
+	if reserved == stored {
+		return
+	}
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	box, ok := ds.boxes[id]
+	if !ok {
+		return
+	}
+	box.UsedBytes += stored - reserved
+	ds.saveLocked()
+}
+
+// List returns the drop boxes of a creator, or all of them if createdBy is empty
+func (ds *DropBoxService) List(createdBy string) []DropBox {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	var boxes []DropBox
+	for _, box := range ds.boxes {
+		if createdBy == "" || box.CreatedBy == createdBy {
+			snapshot := *box
+			snapshot.Hash = ""
+			boxes = append(boxes, snapshot)
+		}
+	}
+
+	sort.Slice(boxes, func(i, j int) bool {
+		return boxes[i].CreatedAt.Before(boxes[j].CreatedAt)
+	})
+	return boxes
+}
+
+// Delete removes a drop box. Files already uploaded through it are kept.
+func (ds *DropBoxService) Delete(id, createdBy string) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	box, ok := ds.boxes[id]
+	if !ok || (createdBy != "" && box.CreatedBy != createdBy) {
+		return ErrDropBoxNotFound
+	}
+
+	delete(ds.boxes, id)
+	return ds.saveLocked()
+}
+
+// saveLocked writes the state file. Callers must hold ds.mu.
+func (ds *DropBoxService) saveLocked() error {
+	boxes := make([]*DropBox, 0, len(ds.boxes))
+	for _, box := range ds.boxes {
+		boxes = append(boxes, box)
+	}
+	return utils.WriteJSONFile(ds.stateFile, boxes)
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"simple-server/src/backend/config"
@@ -8,8 +9,12 @@ import (
 	"strings"
 )
 
+// ErrDestinationDenied means the caller may not upload into a directory
+var ErrDestinationDenied = errors.New("upload destination not writable")
+
 type FileService struct {
 	config *config.Config
+	acl    *ACL
 }
 
 type FileEntry struct {
@@ -26,11 +31,104 @@ type SearchResult struct {
 func NewFileService(cfg *config.Config) *FileService {
 	return &FileService{
 		config: cfg,
+		acl:    NewACL(cfg),
+	}
+}
+
+// CanAccess checks if the identity has a permission on a path relative to the upload directory
+func (fs *FileService) CanAccess(identity *utils.Identity, relativePath, permission string) bool {
+	return fs.acl.Allowed(identity, relativePath, permission)
+}
+
+// CanAccessPath is like CanAccess for a file system path
+func (fs *FileService) CanAccessPath(identity *utils.Identity, fullPath, permission string) bool {
+	return fs.CanAccess(identity, fs.RelativePath(fullPath), permission)
+}
+
+// UploadDestination resolves the directory an upload asks for, relative to
+// the upload directory; an empty one means the incoming directory. Other
+// destinations must be below a writable directory, outside the incoming and
+// private directories, and the identity needs write permission there.
+func (fs *FileService) UploadDestination(identity *utils.Identity, dir string) (string, error) {
+	safePath := utils.SanitizePath(filepath.FromSlash(dir))
+	if safePath == "" || safePath == "." {
+		return fs.config.Storage.IncomingDir, nil
+	}
+
+	if !utils.IsValidPath(fs.config.Storage.UploadDir, safePath) {
+		return "", ErrInvalidDestination
+	}
+	for _, segment := range strings.Split(safePath, string(filepath.Separator)) {
+		if utils.IsHiddenDirectory(segment) {
+			return "", ErrInvalidDestination
+		}
+	}
+	relativePath := filepath.ToSlash(safePath)
+	if utils.IsBlockedPath(relativePath, fs.config.Security.BlockedPaths) {
+		return "", ErrInvalidDestination
 	}
+	fullPath := filepath.Join(fs.config.Storage.UploadDir, safePath)
+	for _, excluded := range []string{fs.config.Storage.IncomingDir, fs.config.Storage.PrivateDir} {
+		if _, inside := utils.RelativeTo(excluded, fullPath); inside {
+			return "", ErrInvalidDestination
+		}
+	}
+
+	if identity == nil || !fs.isWritableDir(relativePath) || !fs.acl.Allowed(identity, relativePath, PermWrite) {
+		return "", ErrDestinationDenied
+	}
+	return fullPath, nil
+}
+
+// isWritableDir checks if a directory is below one of the writable directories
+func (fs *FileService) isWritableDir(relativePath string) bool {
+	segments := splitACLPath(relativePath)
+	for _, dir := range fs.config.Storage.WritableDirs {
+		writable := splitACLPath(dir)
+		if len(writable) <= len(segments) && matchSegments(writable, segments[:len(writable)]) {
+			return true
+		}
+	}
+	return false
 }
 
-// ListFiles lists files in a directory
-func (fs *FileService) ListFiles(relativePath string) ([]FileEntry, error) {
+// IsPublicPath checks if a path relative to the upload directory may be
+// served by the public file routes. The incoming and private directories and
+// blocked paths are only reachable through their own routes and rules.
+func (fs *FileService) IsPublicPath(relativePath string) bool {
+	safePath := utils.SanitizePath(filepath.FromSlash(relativePath))
+	if utils.IsBlockedPath(filepath.ToSlash(safePath), fs.config.Security.BlockedPaths) {
+		return false
+	}
+
+	fullPath := filepath.Join(fs.config.Storage.UploadDir, safePath)
+	for _, excluded := range []string{fs.config.Storage.IncomingDir, fs.config.Storage.PrivateDir} {
+		if _, inside := utils.RelativeTo(excluded, fullPath); inside {
+			return false
+		}
+	}
+	return true
+}
+
+// RelativePath converts a file system path into the path used by ACL rules.
+// Directories configured outside the upload directory (e.g. PrivateDir) are
+// addressed by their base name.
+func (fs *FileService) RelativePath(fullPath string) string {
+	if rel, ok := utils.RelativeTo(fs.config.Storage.UploadDir, fullPath); ok {
+		return filepath.ToSlash(rel)
+	}
+
+	for _, dir := range []string{fs.config.Storage.PrivateDir, fs.config.Storage.IncomingDir} {
+		if rel, ok := utils.RelativeTo(dir, fullPath); ok {
+			return filepath.ToSlash(filepath.Join(filepath.Base(dir), rel))
+		}
+	}
+
+	return filepath.ToSlash(utils.SanitizePath(fullPath))
+}
+
+// ListFiles lists files in a directory visible to the identity
+func (fs *FileService) ListFiles(identity *utils.Identity, relativePath string) ([]FileEntry, error) {
 	// Clean path
 	safePath := utils.SanitizePath(relativePath)
 	fullPath := filepath.Join(fs.config.Storage.UploadDir, safePath)
@@ -40,6 +138,10 @@ func (fs *FileService) ListFiles(relativePath string) ([]FileEntry, error) {
 		return nil, os.ErrInvalid
 	}
 
+	if !fs.acl.AllowedBelow(identity, safePath, PermList) {
+		return nil, os.ErrPermission
+	}
+
 	entries, err := os.ReadDir(fullPath)
 	if err != nil {
 		return nil, err
@@ -72,6 +174,16 @@ func (fs *FileService) ListFiles(relativePath string) ([]FileEntry, error) {
 			}
 		}
 
+		// Only show entries the identity can do something with
+		entryPath := filepath.Join(safePath, entry.Name())
+		if isDir {
+			if !fs.acl.AllowedBelow(identity, entryPath, PermList) && !fs.acl.AllowedBelow(identity, entryPath, PermRead) {
+				continue
+			}
+		} else if !fs.acl.Allowed(identity, entryPath, PermRead) {
+			continue
+		}
+
 		files = append(files, FileEntry{
 			Name:        entry.Name(),
 			IsDirectory: isDir,
@@ -81,8 +193,8 @@ func (fs *FileService) ListFiles(relativePath string) ([]FileEntry, error) {
 	return files, nil
 }
 
-// SearchFiles searches for files
-func (fs *FileService) SearchFiles(query, directory string) ([]SearchResult, error) {
+// SearchFiles searches for files readable by the identity
+func (fs *FileService) SearchFiles(identity *utils.Identity, query, directory string) ([]SearchResult, error) {
 	safePath := utils.SanitizePath(directory)
 	searchPath := filepath.Join(fs.config.Storage.UploadDir, safePath)
 
@@ -91,6 +203,10 @@ func (fs *FileService) SearchFiles(query, directory string) ([]SearchResult, err
 		return nil, os.ErrInvalid
 	}
 
+	if !fs.acl.AllowedBelow(identity, safePath, PermList) {
+		return nil, os.ErrPermission
+	}
+
 	var results []SearchResult
 
 	err := filepath.Walk(searchPath, func(path string, info os.FileInfo, err error) error {
@@ -103,8 +219,14 @@ func (fs *FileService) SearchFiles(query, directory string) ([]SearchResult, err
 			return filepath.SkipDir
 		}
 
+		relativePath, _ := filepath.Rel(fs.config.Storage.UploadDir, path)
+
 		// Skip the directory itself, but continue traversing contents
+		// unless nothing below it can be listed by the identity
 		if info.IsDir() {
+			if relativePath != "." && !fs.acl.AllowedBelow(identity, relativePath, PermList) {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
@@ -114,11 +236,14 @@ func (fs *FileService) SearchFiles(query, directory string) ([]SearchResult, err
 		}
 
 		// Check if in blocked paths
-		relativePath, _ := filepath.Rel(fs.config.Storage.UploadDir, path)
 		if utils.IsBlockedPath(relativePath, fs.config.Security.BlockedPaths) {
 			return nil
 		}
 
+		if !fs.acl.Allowed(identity, relativePath, PermRead) {
+			return nil
+		}
+
 		// Check if filename matches query
 		if strings.Contains(strings.ToLower(info.Name()), strings.ToLower(query)) {
 			results = append(results, SearchResult{
@@ -159,8 +284,8 @@ func (fs *FileService) IsMarkdownFile(filename string) bool {
 	return ext == ".md" || ext == ".markdown"
 }
 
-// ReadMarkdownFile reads the content of a Markdown file
-func (fs *FileService) ReadMarkdownFile(relativePath string) ([]byte, error) {
+// ReadMarkdownFile reads the content of a Markdown file readable by the identity
+func (fs *FileService) ReadMarkdownFile(identity *utils.Identity, relativePath string) ([]byte, error) {
 	safePath := utils.SanitizePath(relativePath)
 	fullPath := filepath.Join(fs.config.Storage.UploadDir, safePath)
 
@@ -173,5 +298,9 @@ func (fs *FileService) ReadMarkdownFile(relativePath string) ([]byte, error) {
 		return nil, os.ErrInvalid
 	}
 
+	if !fs.acl.Allowed(identity, safePath, PermRead) {
+		return nil, os.ErrPermission
+	}
+
 	return os.ReadFile(fullPath)
 }
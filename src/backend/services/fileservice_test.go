@@ -0,0 +1,75 @@
+package services
+
+import (
+	"errors"
+	"path/filepath"
+	"simple-server/src/backend/config"
+	"simple-server/src/backend/utils"
+	"testing"
+)
+
+func TestUploadDestination(t *testing.T) {
+	uploadDir := t.TempDir()
+	cfg := &config.Config{
+		Storage: config.StorageConfig{
+			UploadDir:    uploadDir,
+			IncomingDir:  filepath.Join(uploadDir, "incoming"),
+			PrivateDir:   filepath.Join(uploadDir, "private-files"),
+			WritableDirs: []string{"shared", "projects/*", "incoming", "private-files"},
+		},
+		Security: config.SecurityConfig{
+			BlockedPaths: []string{"archive"},
+			ACLDefault:   "allow",
+			ACL: []config.ACLRule{
+				{Path: "projects/team-a", Groups: []string{"team-a"}, Permissions: []string{"read", "write", "list"}},
+				{Path: "shared/readonly", Users: []string{"*"}, Permissions: []string{"read", "list"}},
+			},
+		},
+	}
+	fs := NewFileService(cfg)
+
+	bob := &utils.Identity{Username: "bob", Groups: []string{"team-a"}}
+	carol := &utils.Identity{Username: "carol"}
+	admin := &utils.Identity{Username: "root", Admin: true}
+	token := &utils.Identity{Username: "ci", PathPrefixes: []string{"shared/builds"}}
+
+	tests := []struct {
+		name     string
+		identity *utils.Identity
+		dir      string
+		want     string
+		wantErr  error
+	}{
+		{name: "empty is incoming", identity: nil, dir: "", want: cfg.Storage.IncomingDir},
+		{name: "dot is incoming", identity: nil, dir: ".", want: cfg.Storage.IncomingDir},
+		{name: "anonymous", identity: nil, dir: "shared", wantErr: ErrDestinationDenied},
+		{name: "writable", identity: carol, dir: "shared", want: filepath.Join(uploadDir, "shared")},
+		{name: "below writable", identity: carol, dir: "shared/a/b", want: filepath.Join(uploadDir, "shared", "a", "b")},
+		{name: "traversal is cleaned", identity: carol, dir: "../shared", want: filepath.Join(uploadDir, "shared")},
+		{name: "not writable", identity: carol, dir: "other", wantErr: ErrDestinationDenied},
+		{name: "glob parent not writable", identity: carol, dir: "projects", wantErr: ErrDestinationDenied},
+		{name: "hidden segment", identity: carol, dir: "shared/.tus", wantErr: ErrInvalidDestination},
+		{name: "blocked", identity: admin, dir: "archive", wantErr: ErrInvalidDestination},
+		{name: "incoming", identity: admin, dir: "incoming/x", wantErr: ErrInvalidDestination},
+		{name: "private", identity: admin, dir: "private-files", wantErr: ErrInvalidDestination},
+		{name: "acl allows group", identity: bob, dir: "projects/team-a", want: filepath.Join(uploadDir, "projects", "team-a")},
+		{name: "acl denies others", identity: carol, dir: "projects/team-a", wantErr: ErrDestinationDenied},
+		{name: "acl default allows", identity: carol, dir: "projects/team-b", want: filepath.Join(uploadDir, "projects", "team-b")},
+		{name: "acl read only", identity: carol, dir: "shared/readonly", wantErr: ErrDestinationDenied},
+		{name: "admin bypasses acl", identity: admin, dir: "shared/readonly", want: filepath.Join(uploadDir, "shared", "readonly")},
+		{name: "token inside prefix", identity: token, dir: "shared/builds/42", want: filepath.Join(uploadDir, "shared", "builds", "42")},
+		{name: "token outside prefix", identity: token, dir: "shared/other", wantErr: ErrDestinationDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := fs.UploadDestination(tt.identity, tt.dir)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UploadDestination(%q) error = %v, want %v", tt.dir, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("UploadDestination(%q) = %q, want %q", tt.dir, got, tt.want)
+			}
+		})
+	}
+}
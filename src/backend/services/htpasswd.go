@@ -0,0 +1,118 @@
+package services
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"os"
+	"simple-server/src/backend/config"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// htpasswdCheckInterval limits how often the htpasswd file is checked for changes
+const htpasswdCheckInterval = 2 * time.Second
+
+// HtpasswdService checks credentials against an Apache-style htpasswd file.
+// Supported entries are bcrypt ($2y$, $2a$, $2b$) and {SHA}. The file is
+// reloaded automatically when its modification time or size changes.
+type HtpasswdService struct {
+	path      string
+	mu        sync.Mutex
+	entries   map[string]string
+	modTime   time.Time
+	size      int64
+	lastCheck time.Time
+}
+
+func NewHtpasswdService(cfg *config.Config) (*HtpasswdService, error) {
+	hs := &HtpasswdService{
+		path: cfg.Security.BasicAuth.HtpasswdFile,
+	}
+
+	info, err := os.Stat(hs.path)
+	if err != nil {
+		return nil, err
+	}
+	if err := hs.load(info); err != nil {
+		return nil, err
+	}
+
+	return hs, nil
+}
+
+// Authenticate checks a username and password against the htpasswd file
+func (hs *HtpasswdService) Authenticate(username, password string) bool {
+	hs.mu.Lock()
+	hs.reloadIfChangedLocked()
+	hash, ok := hs.entries[username]
+	hs.mu.Unlock()
+
+	if !ok {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(hash, "$2y$"), strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"):
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	case strings.HasPrefix(hash, "{SHA}"):
+		sum := sha1.Sum([]byte(password))
+		expected := "{SHA}" + base64.StdEncoding.EncodeToString(sum[:])
+		return subtle.ConstantTimeCompare([]byte(hash), []byte(expected)) == 1
+	default:
+		// MD5 (apr1), crypt and plain text entries are not supported
+		return false
+	}
+}
+
+// reloadIfChangedLocked reloads the file if it changed. Callers must hold hs.mu.
+// A file that becomes unreadable keeps the last loaded entries.
+func (hs *HtpasswdService) reloadIfChangedLocked() {
+	now := time.Now()
+	if now.Sub(hs.lastCheck) < htpasswdCheckInterval {
+		return
+	}
+	hs.lastCheck = now
+
+	info, err := os.Stat(hs.path)
+	if err != nil || (info.ModTime().Equal(hs.modTime) && info.Size() == hs.size) {
+		return
+	}
+	hs.load(info)
+}
+
+// load parses the htpasswd file
+func (hs *HtpasswdService) load(info os.FileInfo) error {
+	file, err := os.Open(hs.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	entries := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		username, hash, ok := strings.Cut(line, ":")
+		if !ok || username == "" {
+			continue
+		}
+		entries[username] = hash
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	hs.entries = entries
+	hs.modTime = info.ModTime()
+	hs.size = info.Size()
+	return nil
+}
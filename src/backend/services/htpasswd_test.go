@@ -0,0 +1,107 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"simple-server/src/backend/config"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func writeHtpasswd(t *testing.T, path string, lines ...string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHtpasswdAuthenticate(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apacheHash := "$2y$" + strings.TrimPrefix(string(hash), "$2a$")
+
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	writeHtpasswd(t, path,
+		"# comment",
+		"alice:"+string(hash),
+		"bob:"+apacheHash,
+		// htpasswd -s, password "password"
+		"carol:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=",
+		// htpasswd -m, password "password"
+		"dave:$apr1$Ac6qU1Dp$6ViZ3WF9NHWdxtxvhSe9U/",
+		"erin:plaintext",
+		":nouser",
+	)
+
+	cfg := &config.Config{}
+	cfg.Security.BasicAuth.HtpasswdFile = path
+	hs, err := NewHtpasswdService(cfg)
+	if err != nil {
+		t.Fatalf("NewHtpasswdService: %v", err)
+	}
+
+	tests := []struct {
+		username string
+		password string
+		want     bool
+	}{
+		{username: "alice", password: "secret", want: true},
+		{username: "alice", password: "wrong", want: false},
+		{username: "bob", password: "secret", want: true},
+		{username: "carol", password: "password", want: true},
+		{username: "carol", password: "Password", want: false},
+		{username: "dave", password: "password", want: false},
+		{username: "erin", password: "plaintext", want: false},
+		{username: "", password: "nouser", want: false},
+		{username: "mallory", password: "secret", want: false},
+		{username: "# comment", password: "", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := hs.Authenticate(tt.username, tt.password); got != tt.want {
+			t.Errorf("Authenticate(%q, %q) = %v, want %v", tt.username, tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestHtpasswdReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	writeHtpasswd(t, path, "carol:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=")
+
+	cfg := &config.Config{}
+	cfg.Security.BasicAuth.HtpasswdFile = path
+	hs, err := NewHtpasswdService(cfg)
+	if err != nil {
+		t.Fatalf("NewHtpasswdService: %v", err)
+	}
+	if !hs.Authenticate("carol", "password") {
+		t.Fatal("carol rejected")
+	}
+
+	// Replace carol by frank, whose SHA password is "secret"
+	writeHtpasswd(t, path, "frank:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=")
+	hs.mu.Lock()
+	hs.lastCheck = time.Time{}
+	hs.mu.Unlock()
+
+	if hs.Authenticate("carol", "password") {
+		t.Error("removed user still accepted after reload")
+	}
+	if !hs.Authenticate("frank", "secret") {
+		t.Error("added user rejected after reload")
+	}
+
+	// An unreadable file keeps the last entries
+	os.Remove(path)
+	hs.mu.Lock()
+	hs.lastCheck = time.Time{}
+	hs.mu.Unlock()
+	if !hs.Authenticate("frank", "secret") {
+		t.Error("entries dropped when the file disappeared")
+	}
+}
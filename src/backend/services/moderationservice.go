@@ -0,0 +1,364 @@
+package services
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"simple-server/src/backend/config"
+	"simple-server/src/backend/utils"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrIncomingNotFound   = errors.New("incoming file not found")
+	ErrInvalidDestination = errors.New("invalid destination")
+	ErrInvalidDisposal    = errors.New("disposal must be delete or quarantine")
+)
+
+// Moderation decisions
+const (
+	ModerationApprove = "approve"
+	ModerationReject  = "reject"
+)
+
+// IncomingFile is a file in the incoming directory waiting for review
+type IncomingFile struct {
+	// Path is relative to the incoming directory and slash-separated
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	IncomingRecord
+}
+
+// IncomingRecord is what is known about an upload beyond the file itself.
+// Files placed in the incoming directory by other means have none.
+type IncomingRecord struct {
+	Uploader     string    `json:"uploader,omitempty"`
+	ClientIP     string    `json:"clientIp,omitempty"`
+	OriginalName string    `json:"originalName,omitempty"`
+	UploadedAt   time.Time `json:"uploadedAt,omitempty"`
+}
+
+// ModerationDecision records the approval or rejection of an incoming file
+type ModerationDecision struct {
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action"`
+	Path      string    `json:"path"`
+	Size      int64     `json:"size"`
+	Uploader  string    `json:"uploader,omitempty"`
+	Moderator string    `json:"moderator"`
+	// Destination is where an approved file went, relative to the upload or
+	// private directory, or the quarantined file of a rejection
+	Destination string `json:"destination,omitempty"`
+	Private     bool   `json:"private,omitempty"`
+	// Disposal is how a rejected file was disposed of: delete or quarantine
+	Disposal string `json:"disposal,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// ApproveTarget is the destination of an approved file
+type ApproveTarget struct {
+	// Dir is relative to the upload directory, or to the private directory if Private is set
+	Dir     string
+	Private bool
+}
+
+// moderationState is the persisted form of the moderation service
+type moderationState struct {
+	Records   map[string]IncomingRecord `json:"records"`
+	Decisions []ModerationDecision      `json:"decisions"`
+}
+
+type ModerationService struct {
+	config        *config.Config
+	uploadService *UploadService
+	stateFile     string
+	mu            sync.Mutex
+	state         moderationState
+}
+
+func NewModerationService(cfg *config.Config, uploadService *UploadService) (*ModerationService, error) {
+	ms := &ModerationService{
+		config:        cfg,
+		uploadService: uploadService,
+		stateFile:     filepath.Join(cfg.Storage.DataDir, "moderation.json"),
+	}
+
+	switch cfg.Moderation.RejectAction {
+	case config.RejectDelete, config.RejectQuarantine:
+	default:
+		return nil, errors.New("moderation.rejectAction must be delete or quarantine")
+	}
+
+	if err := utils.ReadJSONFile(ms.stateFile, &ms.state); err != nil {
+		return nil, err
+	}
+	if ms.state.Records == nil {
+		ms.state.Records = make(map[string]IncomingRecord)
+	}
+
+	return ms, nil
+}
+
+// Track remembers who uploaded a file into the incoming directory. It is
+// best effort: the upload has already succeeded when it is called.
+func (ms *ModerationService) Track(file *StoredFile, req UploadRequest) {
+	rel, ok := utils.RelativeTo(ms.config.Storage.IncomingDir, file.Path)
+	if !ok {
+		return
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.state.Records[filepath.ToSlash(rel)] = IncomingRecord{
+		Uploader:     req.Uploader,
+		ClientIP:     req.ClientIP,
+		OriginalName: req.Filename,
+		UploadedAt:   time.Now().UTC(),
+	}
+	ms.saveLocked()
+}
+
+// Pending lists the files in the incoming directory, oldest first. Hidden
+// files and folders, such as unfinished uploads, are skipped.
+func (ms *ModerationService) Pending() ([]IncomingFile, error) {
+	incomingDir := ms.config.Storage.IncomingDir
+
+	var files []IncomingFile
+	err := filepath.WalkDir(incomingDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == incomingDir {
+			return nil
+		}
+		if utils.IsHiddenFile(d.Name()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(incomingDir, path)
+		files = append(files, IncomingFile{
+			Path:    filepath.ToSlash(rel),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	present := make(map[string]bool, len(files))
+	pending := files[:0]
+	for _, file := range files {
+		present[file.Path] = true
+		record, ok := ms.state.Records[file.Path]
+		// The placeholder page copied in at startup is not an upload
+		if !ok && file.Path == "index.html" {
+			continue
+		}
+		file.IncomingRecord = record
+		pending = append(pending, file)
+	}
+
+	// Forget files that were removed behind our back
+	pruned := false
+	for path := range ms.state.Records {
+		if !present[path] {
+			delete(ms.state.Records, path)
+			pruned = true
+		}
+	}
+	if pruned {
+		ms.saveLocked()
+	}
+
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].ModTime.Before(pending[j].ModTime)
+	})
+	return pending, nil
+}
+
+// Approve moves an incoming file to its destination. The collision policy of
+// the destination applies, so the stored name may differ from the original.
+func (ms *ModerationService) Approve(rel string, target ApproveTarget, moderator string) (*ModerationDecision, error) {
+	srcPath, rel, info, err := ms.resolve(rel)
+	if err != nil {
+		return nil, err
+	}
+
+	root := ms.config.Storage.UploadDir
+	if target.Private {
+		root = ms.config.Storage.PrivateDir
+	}
+	dir, err := ms.destinationDir(root, target)
+	if err != nil {
+		return nil, err
+	}
+
+	ms.mu.Lock()
+	uploader := ms.state.Records[rel].Uploader
+	ms.mu.Unlock()
+
+	// The file was checked when it was uploaded; the moderator's approval
+	// overrides the type checks
+	stored, err := ms.uploadService.Commit(srcPath, UploadRequest{
+		Filename: filepath.Base(srcPath),
+		Dir:      dir,
+		Size:     info.Size(),
+		Uploader: uploader,
+		Approved: true,
+	})
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrIncomingNotFound
+		}
+		return nil, err
+	}
+
+	destination, _ := utils.RelativeTo(root, stored.Path)
+	return ms.decide(ModerationDecision{
+		Action:      ModerationApprove,
+		Path:        rel,
+		Size:        stored.Size,
+		Moderator:   moderator,
+		Destination: filepath.ToSlash(destination),
+		Private:     target.Private,
+	}), nil
+}
+
+// Reject deletes or quarantines an incoming file. An empty disposal uses the configured default.
+func (ms *ModerationService) Reject(rel, disposal, reason, moderator string) (*ModerationDecision, error) {
+	if disposal == "" {
+		disposal = ms.config.Moderation.RejectAction
+	}
+	if disposal != config.RejectDelete && disposal != config.RejectQuarantine {
+		return nil, ErrInvalidDisposal
+	}
+
+	srcPath, rel, info, err := ms.resolve(rel)
+	if err != nil {
+		return nil, err
+	}
+
+	decision := ModerationDecision{
+		Action:    ModerationReject,
+		Path:      rel,
+		Size:      info.Size(),
+		Moderator: moderator,
+		Disposal:  disposal,
+		Reason:    reason,
+	}
+
+	if disposal == config.RejectQuarantine {
+		quarantined, err := ms.uploadService.Quarantine(srcPath, filepath.Base(srcPath))
+		if err != nil {
+			return nil, err
+		}
+		decision.Destination = filepath.Base(quarantined)
+	} else if err := ms.uploadService.Remove(srcPath); err != nil {
+		return nil, err
+	}
+
+	return ms.decide(decision), nil
+}
+
+// Decisions returns the recorded decisions, oldest first. A positive limit keeps the most recent ones.
+func (ms *ModerationService) Decisions(limit int) []ModerationDecision {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	decisions := ms.state.Decisions
+	if limit > 0 && len(decisions) > limit {
+		decisions = decisions[len(decisions)-limit:]
+	}
+	return append([]ModerationDecision(nil), decisions...)
+}
+
+// resolve finds an incoming file by its relative path
+func (ms *ModerationService) resolve(rel string) (string, string, os.FileInfo, error) {
+	clean := utils.SanitizePath(filepath.FromSlash(rel))
+	if clean == "" {
+		return "", "", nil, ErrIncomingNotFound
+	}
+	for _, segment := range strings.Split(clean, string(filepath.Separator)) {
+		if utils.IsHiddenFile(segment) {
+			return "", "", nil, ErrIncomingNotFound
+		}
+	}
+
+	fullPath := filepath.Join(ms.config.Storage.IncomingDir, clean)
+	info, err := os.Lstat(fullPath)
+	if err != nil || !info.Mode().IsRegular() {
+		return "", "", nil, ErrIncomingNotFound
+	}
+	return fullPath, filepath.ToSlash(clean), info, nil
+}
+
+// destinationDir checks the destination of an approval. Public destinations
+// must not lead back into the incoming or private directories.
+func (ms *ModerationService) destinationDir(root string, target ApproveTarget) (string, error) {
+	clean := utils.SanitizePath(filepath.FromSlash(target.Dir))
+	if !utils.IsValidPath(root, clean) {
+		return "", ErrInvalidDestination
+	}
+	if clean != "" {
+		for _, segment := range strings.Split(clean, string(filepath.Separator)) {
+			if utils.IsHiddenDirectory(segment) {
+				return "", ErrInvalidDestination
+			}
+		}
+	}
+
+	dir := filepath.Join(root, clean)
+	if !target.Private {
+		for _, excluded := range []string{ms.config.Storage.IncomingDir, ms.config.Storage.PrivateDir} {
+			if _, inside := utils.RelativeTo(excluded, dir); inside {
+				return "", ErrInvalidDestination
+			}
+		}
+	}
+	return dir, nil
+}
+
+// decide records a decision and forgets the file's upload record. The file
+// has already moved, so a failed save only loses the record.
+func (ms *ModerationService) decide(decision ModerationDecision) *ModerationDecision {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	decision.Time = time.Now().UTC()
+	decision.Uploader = ms.state.Records[decision.Path].Uploader
+	delete(ms.state.Records, decision.Path)
+
+	ms.state.Decisions = append(ms.state.Decisions, decision)
+	if limit := ms.config.Moderation.HistoryLimit; limit > 0 && len(ms.state.Decisions) > limit {
+		ms.state.Decisions = append([]ModerationDecision(nil), ms.state.Decisions[len(ms.state.Decisions)-limit:]...)
+	}
+
+	ms.saveLocked()
+	return &decision
+}
+
+// saveLocked persists the state. Callers must hold ms.mu.
+func (ms *ModerationService) saveLocked() error {
+	return utils.WriteJSONFile(ms.stateFile, ms.state)
+}
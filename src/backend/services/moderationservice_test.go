@@ -0,0 +1,125 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"simple-server/src/backend/config"
+	"strings"
+	"testing"
+)
+
+func newTestModerationService(t *testing.T) (*ModerationService, *UploadService) {
+	t.Helper()
+
+	root := t.TempDir()
+	cfg := &config.Config{
+		Storage: config.StorageConfig{
+			UploadDir:     filepath.Join(root, "files"),
+			IncomingDir:   filepath.Join(root, "files", "incoming"),
+			PrivateDir:    filepath.Join(root, "files", "private-files"),
+			DataDir:       filepath.Join(root, "data"),
+			QuarantineDir: filepath.Join(root, "data", "quarantine"),
+			MaxUploadSize: 1 << 20,
+		},
+		Moderation: config.ModerationConfig{Enabled: true, RejectAction: config.RejectQuarantine, HistoryLimit: 2},
+	}
+
+	uploadService, err := NewUploadService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms, err := NewModerationService(cfg, uploadService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploadService.AddTracker(ms)
+	return ms, uploadService
+}
+
+func TestModerationApprove(t *testing.T) {
+	ms, uploadService := newTestModerationService(t)
+
+	stored, err := uploadService.Store(strings.NewReader("hello"), UploadRequest{Filename: "a.txt", Size: 5, Uploader: "bob", ClientIP: "10.0.0.1"})
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+
+	pending, err := ms.Pending()
+	if err != nil || len(pending) != 1 {
+		t.Fatalf("Pending = %+v, %v", pending, err)
+	}
+	if pending[0].Path != "a.txt" || pending[0].Uploader != "bob" || pending[0].ClientIP != "10.0.0.1" {
+		t.Errorf("pending file = %+v", pending[0])
+	}
+
+	for _, dir := range []string{"incoming", "../incoming/x", "private-files/x", "shared/.hidden"} {
+		if _, err := ms.Approve("a.txt", ApproveTarget{Dir: dir}, "alice"); !errors.Is(err, ErrInvalidDestination) {
+			t.Errorf("Approve into %q error = %v, want %v", dir, err, ErrInvalidDestination)
+		}
+		if _, err := os.Stat(stored.Path); err != nil {
+			t.Fatalf("file moved by a refused approval into %q", dir)
+		}
+	}
+
+	decision, err := ms.Approve("a.txt", ApproveTarget{Dir: "shared"}, "alice")
+	if err != nil {
+		t.Fatalf("Approve: %v", err)
+	}
+	if decision.Destination != "shared/a.txt" || decision.Uploader != "bob" || decision.Moderator != "alice" {
+		t.Errorf("decision = %+v", decision)
+	}
+	if _, err := os.Stat(filepath.Join(ms.config.Storage.UploadDir, "shared", "a.txt")); err != nil {
+		t.Errorf("approved file missing: %v", err)
+	}
+	if pending, _ := ms.Pending(); len(pending) != 0 {
+		t.Errorf("Pending after approval = %+v", pending)
+	}
+	if _, err := ms.Approve("a.txt", ApproveTarget{}, "alice"); !errors.Is(err, ErrIncomingNotFound) {
+		t.Errorf("second Approve error = %v, want %v", err, ErrIncomingNotFound)
+	}
+}
+
+func TestModerationReject(t *testing.T) {
+	ms, uploadService := newTestModerationService(t)
+
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		if _, err := uploadService.Store(strings.NewReader("hello"), UploadRequest{Filename: name, Size: 5, Uploader: "bob"}); err != nil {
+			t.Fatalf("Store: %v", err)
+		}
+	}
+
+	if _, err := ms.Reject("a.txt", "shred", "", "alice"); !errors.Is(err, ErrInvalidDisposal) {
+		t.Errorf("Reject with an unknown disposal error = %v, want %v", err, ErrInvalidDisposal)
+	}
+	if _, err := ms.Reject(".tus/x", "", "", "alice"); !errors.Is(err, ErrIncomingNotFound) {
+		t.Errorf("Reject of a hidden file error = %v, want %v", err, ErrIncomingNotFound)
+	}
+
+	decision, err := ms.Reject("a.txt", "", "spam", "alice")
+	if err != nil {
+		t.Fatalf("Reject: %v", err)
+	}
+	if decision.Disposal != config.RejectQuarantine || decision.Reason != "spam" {
+		t.Errorf("decision = %+v", decision)
+	}
+	if _, err := os.Stat(filepath.Join(ms.config.Storage.QuarantineDir, decision.Destination)); err != nil {
+		t.Errorf("quarantined file missing: %v", err)
+	}
+
+	if _, err := ms.Reject("b.txt", config.RejectDelete, "", "alice"); err != nil {
+		t.Fatalf("Reject: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(ms.config.Storage.IncomingDir, "b.txt")); !os.IsNotExist(err) {
+		t.Errorf("deleted file still present: %v", err)
+	}
+	if _, err := ms.Reject("c.txt", config.RejectDelete, "", "alice"); err != nil {
+		t.Fatalf("Reject: %v", err)
+	}
+
+	// The history keeps the most recent decisions
+	decisions := ms.Decisions(0)
+	if len(decisions) != 2 || decisions[0].Path != "b.txt" || decisions[1].Path != "c.txt" {
+		t.Errorf("Decisions = %+v, want b.txt and c.txt", decisions)
+	}
+}
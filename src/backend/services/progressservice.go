@@ -0,0 +1,280 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"regexp"
+	"simple-server/src/backend/config"
+	"simple-server/src/backend/utils"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidUploadID  = errors.New("invalid upload ID")
+	ErrUploadIDInUse    = errors.New("upload ID in use")
+	ErrProgressNotFound = errors.New("upload not found")
+)
+
+// uploadIDPattern restricts the upload IDs clients may choose
+var uploadIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{8,64}$`)
+
+// progressBuffer is the number of events a subscriber may fall behind
+const progressBuffer = 64
+
+// Upload progress states
+const (
+	ProgressReceiving  = "receiving"
+	ProgressProcessing = "processing"
+	ProgressCompleted  = "completed"
+	ProgressFailed     = "failed"
+)
+
+// UploadProgress is a snapshot of an upload request
+type UploadProgress struct {
+	ID       string `json:"id"`
+	Filename string `json:"filename,omitempty"`
+	Uploader string `json:"uploader"`
+	ClientIP string `json:"clientIp"`
+	Status   string `json:"status"`
+	// Received counts request body bytes; Total is the Content-Length, or -1 if unknown
+	Received int64 `json:"received"`
+	Total    int64 `json:"total"`
+	// Rate is the average in bytes per second, and ETA the seconds left at that rate
+	Rate float64 `json:"rate"`
+	ETA  float64 `json:"eta,omitempty"`
+	// HTTPStatus and Error are the outcome of a finished upload
+	HTTPStatus int           `json:"httpStatus,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Files      []*StoredFile `json:"files,omitempty"`
+	StartedAt  time.Time     `json:"startedAt"`
+	UpdatedAt  time.Time     `json:"updatedAt"`
+	FinishedAt *time.Time    `json:"finishedAt,omitempty"`
+}
+
+// progressEntry is the live state of an upload
+type progressEntry struct {
+	progress  UploadProgress
+	lastEvent time.Time
+}
+
+// ProgressService tracks uploads while they are received and stored, and
+// publishes their progress to subscribers. Finished uploads are kept for the
+// configured retention so clients can fetch the outcome.
+type ProgressService struct {
+	config      *config.Config
+	mu          sync.Mutex
+	uploads     map[string]*progressEntry
+	subscribers map[chan UploadProgress]struct{}
+}
+
+func NewProgressService(cfg *config.Config) *ProgressService {
+	return &ProgressService{
+		config:      cfg,
+		uploads:     make(map[string]*progressEntry),
+		subscribers: make(map[chan UploadProgress]struct{}),
+	}
+}
+
+// Start registers an upload. A client may choose the ID to watch the upload
+// from elsewhere; otherwise a random one is assigned.
+func (ps *ProgressService) Start(id, filename, uploader, clientIP string, total int64) (string, error) {
+	if id == "" {
+		token, err := utils.RandomToken(16)
+		if err != nil {
+			return "", err
+		}
+		id = token
+	} else if !uploadIDPattern.MatchString(id) {
+		return "", ErrInvalidUploadID
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	ps.pruneLocked()
+	if _, exists := ps.uploads[id]; exists {
+		return "", ErrUploadIDInUse
+	}
+
+	now := time.Now().UTC()
+	entry := &progressEntry{
+		progress: UploadProgress{
+			ID:        id,
+			Filename:  filename,
+			Uploader:  uploader,
+			ClientIP:  clientIP,
+			Status:    ProgressReceiving,
+			Total:     total,
+			StartedAt: now,
+			UpdatedAt: now,
+		},
+		lastEvent: now,
+	}
+	ps.uploads[id] = entry
+	ps.publishLocked(entry)
+	return id, nil
+}
+
+// Reader counts the bytes read from src as received by the upload. The upload
+// moves on to processing when src is exhausted.
+func (ps *ProgressService) Reader(id string, src io.ReadCloser) io.ReadCloser {
+	return &progressReader{ReadCloser: src, ps: ps, id: id}
+}
+
+// Stored adds a stored file to the outcome of an upload
+func (ps *ProgressService) Stored(id string, file *StoredFile) {
+	ps.update(id, true, func(p *UploadProgress) {
+		p.Files = append(p.Files, file)
+	})
+}
+
+// Finish records the outcome of an upload. An empty message marks it completed.
+func (ps *ProgressService) Finish(id string, httpStatus int, message string) {
+	ps.update(id, true, func(p *UploadProgress) {
+		finished := time.Now().UTC()
+		p.FinishedAt = &finished
+		p.HTTPStatus = httpStatus
+		p.Error = message
+		p.Status = ProgressCompleted
+		if message != "" {
+			p.Status = ProgressFailed
+		}
+	})
+}
+
+// Get returns the progress of an upload
+func (ps *ProgressService) Get(id string) (*UploadProgress, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	ps.pruneLocked()
+	entry, ok := ps.uploads[id]
+	if !ok {
+		return nil, ErrProgressNotFound
+	}
+	snapshot := entry.snapshot(time.Now())
+	return &snapshot, nil
+}
+
+// List returns the uploads in progress and recently finished, oldest first
+func (ps *ProgressService) List() []UploadProgress {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	ps.pruneLocked()
+	now := time.Now()
+	uploads := make([]UploadProgress, 0, len(ps.uploads))
+	for _, entry := range ps.uploads {
+		uploads = append(uploads, entry.snapshot(now))
+	}
+	sort.Slice(uploads, func(i, j int) bool {
+		return uploads[i].StartedAt.Before(uploads[j].StartedAt)
+	})
+	return uploads
+}
+
+// Subscribe returns a channel of progress events and a function to stop
+// them. Subscribers that fall behind are dropped and their channel closed,
+// so they can reconnect and start again from List.
+func (ps *ProgressService) Subscribe() (<-chan UploadProgress, func()) {
+	ch := make(chan UploadProgress, progressBuffer)
+
+	ps.mu.Lock()
+	ps.subscribers[ch] = struct{}{}
+	ps.mu.Unlock()
+
+	return ch, func() {
+		ps.mu.Lock()
+		defer ps.mu.Unlock()
+		if _, ok := ps.subscribers[ch]; ok {
+			delete(ps.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// update changes an upload and publishes it, at most once per interval
+// unless force is set
+func (ps *ProgressService) update(id string, force bool, change func(p *UploadProgress)) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	entry, ok := ps.uploads[id]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	change(&entry.progress)
+	entry.progress.UpdatedAt = now.UTC()
+
+	if force || now.Sub(entry.lastEvent) >= ps.config.Progress.Interval {
+		entry.lastEvent = now
+		ps.publishLocked(entry)
+	}
+}
+
+// publishLocked sends an upload to the subscribers. Callers must hold ps.mu.
+func (ps *ProgressService) publishLocked(entry *progressEntry) {
+	snapshot := entry.snapshot(time.Now())
+	for ch := range ps.subscribers {
+		select {
+		case ch <- snapshot:
+		default:
+			delete(ps.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// pruneLocked forgets uploads that finished longer than the retention ago. Callers must hold ps.mu.
+func (ps *ProgressService) pruneLocked() {
+	cutoff := time.Now().Add(-ps.config.Progress.Retention)
+	for id, entry := range ps.uploads {
+		if finished := entry.progress.FinishedAt; finished != nil && finished.Before(cutoff) {
+			delete(ps.uploads, id)
+		}
+	}
+}
+
+// snapshot copies the progress and computes its rate and ETA
+func (e *progressEntry) snapshot(now time.Time) UploadProgress {
+	p := e.progress
+	p.Files = append([]*StoredFile(nil), p.Files...)
+
+	end := now
+	if p.FinishedAt != nil {
+		end = *p.FinishedAt
+	}
+	if elapsed := end.Sub(p.StartedAt).Seconds(); elapsed > 0 {
+		p.Rate = float64(p.Received) / elapsed
+	}
+	if p.Status == ProgressReceiving && p.Total > p.Received && p.Rate > 0 {
+		p.ETA = float64(p.Total-p.Received) / p.Rate
+	}
+	return p
+}
+
+// progressReader counts the bytes of a request body
+type progressReader struct {
+	io.ReadCloser
+	ps   *ProgressService
+	id   string
+	done bool
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	done := err == io.EOF && !r.done
+	if n > 0 || done {
+		r.done = r.done || done
+		r.ps.update(r.id, done, func(progress *UploadProgress) {
+			progress.Received += int64(n)
+			if done && progress.Status == ProgressReceiving {
+				progress.Status = ProgressProcessing
+			}
+		})
+	}
+	return n, err
+}
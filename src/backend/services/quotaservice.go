@@ -0,0 +1,511 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"path/filepath"
+	"simple-server/src/backend/config"
+	"simple-server/src/backend/utils"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrQuotaExceeded and ErrInsufficientSpace are wrapped with the limit that was reached
+	ErrQuotaExceeded     = errors.New("storage quota exceeded")
+	ErrInsufficientSpace = errors.New("insufficient disk space")
+)
+
+// QuotaUsage is the usage of a user or directory quota. A limit of zero is unlimited.
+type QuotaUsage struct {
+	Name  string `json:"name"`
+	Used  int64  `json:"used"`
+	Limit int64  `json:"limit"`
+}
+
+// DiskUsage is the free space left for uploads
+type DiskUsage struct {
+	// Free is unknown (-1) where free space cannot be measured
+	Free    int64 `json:"free"`
+	MinFree int64 `json:"minFree"`
+}
+
+// reserveChunk is the least a reservation grows by once an upload outgrows it,
+// so that the quotas are not consulted for every write
+const reserveChunk = 1 << 20
+
+// dirQuota is a directory quota and the bytes currently stored below it
+type dirQuota struct {
+	name     string
+	path     string
+	limit    int64
+	used     int64
+	reserved int64
+}
+
+// QuotaService tracks the bytes stored per user and per directory. The
+// upload directory is measured once at startup; uploads, moves and deletions
+// made through the server then update the totals incrementally.
+type QuotaService struct {
+	config    *config.Config
+	stateFile string
+	mu        sync.Mutex
+	// owners maps the absolute path of each upload to its uploader and is persisted
+	owners map[string]string
+	// sizes holds the size of every counted file by absolute path
+	sizes map[string]int64
+	users map[string]int64
+	dirs  []*dirQuota
+	// reserved holds the bytes claimed per user by uploads in progress, and
+	// pending the claimed bytes not yet written to disk
+	reserved map[string]int64
+	pending  int64
+}
+
+func NewQuotaService(cfg *config.Config) (*QuotaService, error) {
+	qs := &QuotaService{
+		config:    cfg,
+		stateFile: filepath.Join(cfg.Storage.DataDir, "quotas.json"),
+		owners:    make(map[string]string),
+		sizes:     make(map[string]int64),
+		users:     make(map[string]int64),
+		reserved:  make(map[string]int64),
+	}
+
+	if cfg.Quota.PerUser < 0 || cfg.Quota.MinFreeSpace < 0 {
+		return nil, errors.New("quota limits must not be negative")
+	}
+	for _, user := range cfg.Quota.Users {
+		if user.Username == "" || user.Bytes < 0 {
+			return nil, fmt.Errorf("invalid quota for user %q", user.Username)
+		}
+	}
+	for _, dir := range cfg.Quota.Dirs {
+		clean := utils.SanitizePath(filepath.FromSlash(dir.Path))
+		if !utils.IsValidPath(cfg.Storage.UploadDir, clean) || dir.Bytes < 0 {
+			return nil, fmt.Errorf("invalid quota for directory %q", dir.Path)
+		}
+		path, err := filepath.Abs(filepath.Join(cfg.Storage.UploadDir, clean))
+		if err != nil {
+			return nil, err
+		}
+		qs.dirs = append(qs.dirs, &dirQuota{
+			name:  "/" + filepath.ToSlash(clean),
+			path:  path,
+			limit: dir.Bytes,
+		})
+	}
+
+	if err := utils.ReadJSONFile(qs.stateFile, &qs.owners); err != nil {
+		return nil, err
+	}
+
+	roots := []string{cfg.Storage.UploadDir}
+	if _, inside := utils.RelativeTo(cfg.Storage.UploadDir, cfg.Storage.PrivateDir); !inside {
+		roots = append(roots, cfg.Storage.PrivateDir)
+	}
+	for _, root := range roots {
+		if err := qs.measure(root); err != nil {
+			return nil, err
+		}
+	}
+
+	// Forget uploads that were removed while the server was down
+	pruned := false
+	for path, owner := range qs.owners {
+		size, ok := qs.sizes[path]
+		if !ok {
+			delete(qs.owners, path)
+			pruned = true
+			continue
+		}
+		qs.users[owner] += size
+	}
+	if pruned {
+		if err := qs.saveLocked(); err != nil {
+			return nil, err
+		}
+	}
+
+	return qs, nil
+}
+
+// measure counts the files below root. Hidden files, such as unfinished
+// uploads, are not counted.
+func (qs *QuotaService) measure(root string) error {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if path != root && utils.IsHiddenFile(d.Name()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		qs.addLocked(path, info.Size(), "")
+		return nil
+	})
+	return err
+}
+
+// Room returns how many bytes an upload into dir may store and the error to
+// report if it stores more. The uploader's quota is skipped if uploader is
+// empty. The error is nil if no limit applies. Bytes reserved by uploads in
+// progress count as used.
+func (qs *QuotaService) Room(uploader, dir string) (int64, error) {
+	dirPath, err := filepath.Abs(dir)
+	if err != nil {
+		return 0, err
+	}
+	free := qs.measureFree(dirPath)
+
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+	return qs.roomLocked(uploader, dirPath, free)
+}
+
+// Reserve claims size bytes for an upload into dir, so that concurrent
+// uploads cannot together exceed a limit. The reservation grows as more bytes
+// are written through it; call Release once the file is tracked or has failed.
+func (qs *QuotaService) Reserve(uploader, dir string, size int64) (*QuotaReservation, error) {
+	dirPath, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	free := qs.measureFree(dirPath)
+
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+
+	if room, exceeded := qs.roomLocked(uploader, dirPath, free); exceeded != nil && size > room {
+		return nil, exceeded
+	}
+
+	r := &QuotaReservation{qs: qs, uploader: uploader, dirPath: dirPath}
+	for _, d := range qs.dirs {
+		if _, inside := utils.RelativeTo(d.path, dirPath); inside {
+			r.dirs = append(r.dirs, d)
+		}
+	}
+	r.holdLocked(size)
+	qs.pending += size
+	return r, nil
+}
+
+// roomLocked computes Room with the free disk space measured beforehand.
+// Callers must hold qs.mu.
+func (qs *QuotaService) roomLocked(uploader, dirPath string, free int64) (int64, error) {
+	room := int64(math.MaxInt64)
+	var exceeded error
+	limit := func(left int64, err error) {
+		if left < room {
+			room, exceeded = left, err
+		}
+	}
+
+	if uploader != "" {
+		if quota := qs.userLimit(uploader); quota > 0 {
+			limit(quota-qs.users[uploader]-qs.reserved[uploader], fmt.Errorf("%w: %s may store %d bytes", ErrQuotaExceeded, uploader, quota))
+		}
+	}
+	for _, d := range qs.dirs {
+		if _, inside := utils.RelativeTo(d.path, dirPath); inside && d.limit > 0 {
+			limit(d.limit-d.used-d.reserved, fmt.Errorf("%w: %s may hold %d bytes", ErrQuotaExceeded, d.name, d.limit))
+		}
+	}
+	if minFree := qs.config.Quota.MinFreeSpace; minFree > 0 && free >= 0 {
+		limit(free-qs.pending-minFree, fmt.Errorf("%w: %d bytes must stay free", ErrInsufficientSpace, minFree))
+	}
+
+	if room < 0 {
+		room = 0
+	}
+	return room, exceeded
+}
+
+// measureFree returns the free space of the filesystem holding path, or -1
+// if it is unknown or no free space has to be kept
+func (qs *QuotaService) measureFree(path string) int64 {
+	if qs.config.Quota.MinFreeSpace <= 0 {
+		return -1
+	}
+	return qs.diskFree(path)
+}
+
+// QuotaReservation holds quota for an upload in progress. Its methods may be
+// called on a nil reservation, which holds nothing.
+type QuotaReservation struct {
+	qs       *QuotaService
+	uploader string
+	dirPath  string
+	dirs     []*dirQuota
+	// reserved is the bytes held, and written the bytes already on disk
+	reserved int64
+	written  int64
+}
+
+// Write counts bytes written to the upload, growing the reservation when the
+// upload outgrows it. It fails with the quota error once no room is left.
+func (r *QuotaReservation) Write(p []byte) (int, error) {
+	if err := r.add(int64(len(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// add counts n bytes written to the upload
+func (r *QuotaReservation) add(n int64) error {
+	if r == nil {
+		return nil
+	}
+
+	var free int64 = -1
+	if r.written+n > r.reserved {
+		free = r.qs.measureFree(r.dirPath)
+	}
+
+	r.qs.mu.Lock()
+	defer r.qs.mu.Unlock()
+
+	// Bytes within the reservation are no longer pending once written
+	covered := r.reserved - r.written
+	if covered > n {
+		covered = n
+	} else if covered < 0 {
+		covered = 0
+	}
+
+	if need := r.written + n - r.reserved; need > 0 {
+		room, exceeded := r.qs.roomLocked(r.uploader, r.dirPath, free)
+		if exceeded != nil && need > room {
+			return exceeded
+		}
+		// Near a limit, only claim what was written so other uploads keep their share
+		grow := int64(reserveChunk)
+		if grow < need || (exceeded != nil && grow > room) {
+			grow = need
+		}
+		r.holdLocked(grow)
+		r.qs.pending += grow - need
+	}
+	r.qs.pending -= covered
+	r.written += n
+	return nil
+}
+
+// settle counts the upload as total bytes written, e.g. after the data
+// arrived without passing through Write
+func (r *QuotaReservation) settle(total int64) error {
+	if r == nil {
+		return nil
+	}
+	return r.add(total - r.written)
+}
+
+// Release returns the bytes held. Written bytes of a stored file are counted
+// by Track instead, so Release is called after it.
+func (r *QuotaReservation) Release() {
+	if r == nil {
+		return
+	}
+
+	r.qs.mu.Lock()
+	defer r.qs.mu.Unlock()
+
+	if unwritten := r.reserved - r.written; unwritten > 0 {
+		r.qs.pending -= unwritten
+	}
+	r.holdLocked(-r.reserved)
+	r.written = 0
+}
+
+// holdLocked adds size bytes to the reservation without changing the pending
+// bytes. Callers must hold qs.mu.
+func (r *QuotaReservation) holdLocked(size int64) {
+	r.reserved += size
+	if r.uploader != "" {
+		r.qs.reserved[r.uploader] += size
+		if r.qs.reserved[r.uploader] <= 0 {
+			delete(r.qs.reserved, r.uploader)
+		}
+	}
+	for _, d := range r.dirs {
+		d.reserved += size
+	}
+}
+
+// Track adds a stored upload to the usage of its uploader and directories.
+// A file it replaces is subtracted first.
+func (qs *QuotaService) Track(file *StoredFile, req UploadRequest) {
+	path, err := filepath.Abs(file.Path)
+	if err != nil {
+		return
+	}
+
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+
+	_, owned := qs.owners[path]
+	qs.removeLocked(path)
+	qs.addLocked(path, file.Size, req.Uploader)
+	if owned || req.Uploader != "" {
+		qs.saveLocked()
+	}
+}
+
+// Untrack subtracts a file that was moved away or deleted
+func (qs *QuotaService) Untrack(path string) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+
+	_, owned := qs.owners[path]
+	qs.removeLocked(path)
+	if owned {
+		qs.saveLocked()
+	}
+}
+
+// UserUsage returns the usage of a user's quota
+func (qs *QuotaService) UserUsage(username string) QuotaUsage {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+
+	return QuotaUsage{Name: username, Used: qs.users[username], Limit: qs.userLimit(username)}
+}
+
+// Users returns the usage of every user who stored files or has a quota of their own
+func (qs *QuotaService) Users() []QuotaUsage {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+
+	names := make(map[string]bool)
+	for name := range qs.users {
+		names[name] = true
+	}
+	for _, user := range qs.config.Quota.Users {
+		names[user.Username] = true
+	}
+
+	usage := make([]QuotaUsage, 0, len(names))
+	for name := range names {
+		usage = append(usage, QuotaUsage{Name: name, Used: qs.users[name], Limit: qs.userLimit(name)})
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		return usage[i].Name < usage[j].Name
+	})
+	return usage
+}
+
+// Dirs returns the usage of the directory quotas
+func (qs *QuotaService) Dirs() []QuotaUsage {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+
+	usage := make([]QuotaUsage, 0, len(qs.dirs))
+	for _, d := range qs.dirs {
+		usage = append(usage, QuotaUsage{Name: d.name, Used: d.used, Limit: d.limit})
+	}
+	return usage
+}
+
+// Disk returns the free space of the upload directory's filesystem
+func (qs *QuotaService) Disk() DiskUsage {
+	return DiskUsage{
+		Free:    qs.diskFree(qs.config.Storage.UploadDir),
+		MinFree: qs.config.Quota.MinFreeSpace,
+	}
+}
+
+// diskFree measures the free space of the filesystem holding path, or the
+// nearest existing parent. It returns -1 if free space cannot be measured.
+func (qs *QuotaService) diskFree(path string) int64 {
+	for {
+		free, err := utils.DiskFree(path)
+		if err == nil {
+			if free > math.MaxInt64 {
+				return math.MaxInt64
+			}
+			return int64(free)
+		}
+		parent := filepath.Dir(path)
+		if !errors.Is(err, fs.ErrNotExist) || parent == path {
+			return -1
+		}
+		path = parent
+	}
+}
+
+// userLimit returns the quota of a user
+func (qs *QuotaService) userLimit(username string) int64 {
+	for _, user := range qs.config.Quota.Users {
+		if user.Username == username {
+			return user.Bytes
+		}
+	}
+	return qs.config.Quota.PerUser
+}
+
+// addLocked counts a file. Callers must hold qs.mu, except during startup.
+func (qs *QuotaService) addLocked(path string, size int64, owner string) {
+	qs.sizes[path] = size
+	if owner != "" {
+		qs.owners[path] = owner
+		qs.users[owner] += size
+	}
+	for _, d := range qs.dirs {
+		if _, inside := utils.RelativeTo(d.path, path); inside {
+			d.used += size
+		}
+	}
+}
+
+// removeLocked stops counting a file. Callers must hold qs.mu.
+func (qs *QuotaService) removeLocked(path string) {
+	size, ok := qs.sizes[path]
+	if !ok {
+		return
+	}
+	delete(qs.sizes, path)
+
+	if owner, ok := qs.owners[path]; ok {
+		delete(qs.owners, path)
+		qs.users[owner] -= size
+		if qs.users[owner] <= 0 {
+			delete(qs.users, owner)
+		}
+	}
+	for _, d := range qs.dirs {
+		if _, inside := utils.RelativeTo(d.path, path); inside {
+			d.used -= size
+		}
+	}
+}
+
+// saveLocked persists the uploaders of stored files. Callers must hold qs.mu.
+func (qs *QuotaService) saveLocked() error {
+	return utils.WriteJSONFile(qs.stateFile, qs.owners)
+}
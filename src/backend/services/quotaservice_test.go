@@ -0,0 +1,128 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"simple-server/src/backend/config"
+	"testing"
+)
+
+func newTestQuotaService(t *testing.T, quota config.QuotaConfig) (*QuotaService, string) {
+	t.Helper()
+
+	uploadDir := filepath.Join(t.TempDir(), "files")
+	if err := os.MkdirAll(filepath.Join(uploadDir, "shared"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// Existing files count towards directory quotas but no user
+	if err := os.WriteFile(filepath.Join(uploadDir, "shared", "old.bin"), make([]byte, 10), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Storage: config.StorageConfig{
+			UploadDir:  uploadDir,
+			PrivateDir: filepath.Join(uploadDir, "private-files"),
+			DataDir:    filepath.Join(t.TempDir(), "data"),
+		},
+		Quota: quota,
+	}
+	qs, err := NewQuotaService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return qs, uploadDir
+}
+
+func TestQuotaReservations(t *testing.T) {
+	qs, uploadDir := newTestQuotaService(t, config.QuotaConfig{
+		Enabled: true,
+		PerUser: 100,
+		Users:   []config.UserQuota{{Username: "alice", Bytes: 0}},
+		Dirs:    []config.DirQuota{{Path: "shared", Bytes: 150}},
+	})
+	shared := filepath.Join(uploadDir, "shared")
+
+	if room, err := qs.Room("bob", shared); room != 100 || err == nil {
+		t.Errorf("Room = %d, %v, want 100 and the user quota error", room, err)
+	}
+	if room, _ := qs.Room("alice", shared); room != 140 {
+		t.Errorf("Room of an unlimited user = %d, want the directory's 140", room)
+	}
+
+	first, err := qs.Reserve("bob", shared, 60)
+	if err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	if _, err := qs.Reserve("bob", shared, 60); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("second Reserve error = %v, want %v", err, ErrQuotaExceeded)
+	}
+	if room, _ := qs.Room("alice", shared); room != 80 {
+		t.Errorf("Room of another user = %d, want 80 left in the directory", room)
+	}
+
+	// Writing past the reservation grows it, up to the limit
+	if _, err := first.Write(make([]byte, 90)); err != nil {
+		t.Fatalf("Write within the quota: %v", err)
+	}
+	if _, err := first.Write(make([]byte, 20)); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Write past the quota error = %v, want %v", err, ErrQuotaExceeded)
+	}
+
+	// A stored file is tracked before its reservation is released
+	path := filepath.Join(shared, "new.bin")
+	qs.Track(&StoredFile{Path: path, Size: 90}, UploadRequest{Uploader: "bob"})
+	first.Release()
+
+	if usage := qs.UserUsage("bob"); usage.Used != 90 || usage.Limit != 100 {
+		t.Errorf("UserUsage = %+v", usage)
+	}
+	if room, _ := qs.Room("bob", shared); room != 10 {
+		t.Errorf("Room after storing = %d, want 10", room)
+	}
+
+	qs.Untrack(path)
+	if room, _ := qs.Room("bob", shared); room != 100 {
+		t.Errorf("Room after deleting = %d, want 100", room)
+	}
+
+	// Releasing twice or a nil reservation does nothing
+	first.Release()
+	var none *QuotaReservation
+	none.Release()
+	if _, err := none.Write(make([]byte, 10)); err != nil {
+		t.Errorf("Write to a nil reservation: %v", err)
+	}
+	if room, _ := qs.Room("alice", shared); room != 140 {
+		t.Errorf("Room after releasing = %d, want 140", room)
+	}
+}
+
+func TestQuotaOwnersPersist(t *testing.T) {
+	qs, uploadDir := newTestQuotaService(t, config.QuotaConfig{Enabled: true, PerUser: 100})
+
+	path := filepath.Join(uploadDir, "shared", "new.bin")
+	if err := os.WriteFile(path, make([]byte, 30), 0644); err != nil {
+		t.Fatal(err)
+	}
+	qs.Track(&StoredFile{Path: path, Size: 30}, UploadRequest{Uploader: "bob"})
+
+	restarted, err := NewQuotaService(qs.config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used := restarted.UserUsage("bob").Used; used != 30 {
+		t.Errorf("bob uses %d bytes after restart, want 30", used)
+	}
+
+	// Files removed while the server was down are forgotten
+	os.Remove(path)
+	restarted, err = NewQuotaService(qs.config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used := restarted.UserUsage("bob").Used; used != 0 {
+		t.Errorf("bob uses %d bytes after the file was removed, want 0", used)
+	}
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"simple-server/src/backend/config"
+	"strings"
+)
+
+// Scan verdicts
+const (
+	ScanClean     = "clean"
+	ScanInfected  = "infected"
+	ScanUnscanned = "unscanned"
+)
+
+// ScanResult is the verdict of a malware scan
+type ScanResult struct {
+	Verdict string `json:"verdict"`
+	// Signature names the malware found in an infected file
+	Signature string `json:"signature,omitempty"`
+	Scanner   string `json:"scanner"`
+}
+
+// Scanner checks a file for malware. An error means the file could not be
+// scanned, not that it is infected.
+type Scanner interface {
+	Scan(ctx context.Context, path string) (*ScanResult, error)
+}
+
+// NewScanner creates the scanner selected in the config, or nil if scanning is disabled
+func NewScanner(cfg *config.Config) (Scanner, error) {
+	scan := cfg.Scan
+	if !scan.Enabled {
+		return nil, nil
+	}
+
+	switch scan.FailurePolicy {
+	case config.ScanFailOpen, config.ScanFailClosed:
+	default:
+		return nil, fmt.Errorf("invalid scan failure policy %q", scan.FailurePolicy)
+	}
+
+	switch scan.Scanner {
+	case config.ScannerClamd:
+		return NewClamdScanner(scan.Address)
+	case config.ScannerCommand:
+		return NewCommandScanner(scan.Command)
+	default:
+		return nil, fmt.Errorf("invalid scanner %q", scan.Scanner)
+	}
+}
+
+// clamdChunkSize is the size of the chunks streamed to clamd; it must stay
+// below the daemon's StreamMaxLength
+const clamdChunkSize = 64 * 1024
+
+// ClamdScanner streams files to a clamd daemon with the INSTREAM command
+type ClamdScanner struct {
+	network string
+	address string
+}
+
+// NewClamdScanner parses an address of the form tcp://host:port or unix:///path
+func NewClamdScanner(address string) (*ClamdScanner, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid clamd address %q: %w", address, err)
+	}
+
+	switch u.Scheme {
+	case "tcp":
+		if u.Host == "" {
+			return nil, fmt.Errorf("invalid clamd address %q", address)
+		}
+		return &ClamdScanner{network: "tcp", address: u.Host}, nil
+	case "unix":
+		if u.Path == "" {
+			return nil, fmt.Errorf("invalid clamd address %q", address)
+		}
+		return &ClamdScanner{network: "unix", address: u.Path}, nil
+	default:
+		return nil, fmt.Errorf("clamd address %q must start with tcp:// or unix://", address)
+	}
+}
+
+// Scan sends the file to clamd and reads its verdict. Replies look like
+// "stream: OK", "stream: <signature> FOUND" or "<message> ERROR".
+func (s *ClamdScanner) Scan(ctx context.Context, path string) (*ScanResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if err := clamdStream(conn, f); err != nil {
+		return nil, err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && !(err == io.EOF && reply != "") {
+		return nil, fmt.Errorf("reading clamd reply: %w", err)
+	}
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	reply = strings.TrimPrefix(reply, "stream: ")
+
+	switch {
+	case reply == "OK":
+		return &ScanResult{Verdict: ScanClean, Scanner: config.ScannerClamd}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return &ScanResult{
+			Verdict:   ScanInfected,
+			Signature: strings.TrimSuffix(reply, " FOUND"),
+			Scanner:   config.ScannerClamd,
+		}, nil
+	default:
+		return nil, fmt.Errorf("clamd: %s", reply)
+	}
+}
+
+// clamdStream writes the INSTREAM command followed by the file as
+// length-prefixed chunks and the zero-length chunk that ends the stream
+func clamdStream(w io.Writer, src io.Reader) error {
+	if _, err := io.WriteString(w, "zINSTREAM\x00"); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 4+clamdChunkSize)
+	for {
+		n, err := src.Read(buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf, uint32(n))
+			if _, werr := w.Write(buf[:4+n]); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := w.Write([]byte{0, 0, 0, 0})
+	return err
+}
+
+// CommandScanner runs an external program such as clamdscan on each file.
+// Exit code 0 means clean and 1 infected, as with the ClamAV tools; anything
+// else is a scan failure.
+type CommandScanner struct {
+	command []string
+}
+
+func NewCommandScanner(command []string) (*CommandScanner, error) {
+	if len(command) == 0 || command[0] == "" {
+		return nil, errors.New("scan.command must name a program")
+	}
+	return &CommandScanner{command: command}, nil
+}
+
+// Scan runs the command. The last line of its output names the malware of an infected file.
+func (s *CommandScanner) Scan(ctx context.Context, path string) (*ScanResult, error) {
+	args := make([]string, 0, len(s.command))
+	substituted := false
+	for _, arg := range s.command[1:] {
+		if strings.Contains(arg, "{file}") {
+			arg = strings.ReplaceAll(arg, "{file}", path)
+			substituted = true
+		}
+		args = append(args, arg)
+	}
+	if !substituted {
+		args = append(args, path)
+	}
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.command[0], args...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return &ScanResult{Verdict: ScanClean, Scanner: config.ScannerCommand}, nil
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 1:
+		return &ScanResult{
+			Verdict:   ScanInfected,
+			Signature: strings.TrimSuffix(lastLine(output.String(), path), " FOUND"),
+			Scanner:   config.ScannerCommand,
+		}, nil
+	default:
+		return nil, fmt.Errorf("%s: %w: %s", s.command[0], err, lastLine(output.String(), path))
+	}
+}
+
+// lastLine returns the last non-empty line of scanner output with the
+// scanned path removed, so temporary file names do not leak into verdicts
+func lastLine(output, path string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	line := strings.TrimSpace(lines[len(lines)-1])
+	line = strings.TrimSpace(strings.TrimPrefix(line, path+":"))
+	return strings.TrimSpace(strings.ReplaceAll(line, path, ""))
+}
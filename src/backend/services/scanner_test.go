@@ -0,0 +1,135 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeClamd accepts one INSTREAM session per connection, collects the
+// streamed bytes and answers with reply
+func fakeClamd(t *testing.T, reply string) (string, <-chan []byte) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		command, err := r.ReadString(0)
+		if err != nil || command != "zINSTREAM\x00" {
+			received <- nil
+			return
+		}
+
+		var data bytes.Buffer
+		for {
+			var size uint32
+			if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+				received <- nil
+				return
+			}
+			if size == 0 {
+				break
+			}
+			if _, err := io.CopyN(&data, r, int64(size)); err != nil {
+				received <- nil
+				return
+			}
+		}
+
+		received <- data.Bytes()
+		io.WriteString(conn, reply)
+	}()
+
+	return "tcp://" + ln.Addr().String(), received
+}
+
+func TestClamdScanner(t *testing.T) {
+	tests := []struct {
+		name      string
+		reply     string
+		verdict   string
+		signature string
+		wantErr   bool
+	}{
+		{name: "clean", reply: "stream: OK\x00", verdict: ScanClean},
+		{name: "infected", reply: "stream: Eicar-Test-Signature FOUND\x00", verdict: ScanInfected, signature: "Eicar-Test-Signature"},
+		{name: "error", reply: "INSTREAM size limit exceeded. ERROR\x00", wantErr: true},
+	}
+
+	// Larger than one chunk, so the framing of several chunks is covered
+	content := bytes.Repeat([]byte("0123456789abcdef"), clamdChunkSize/8)
+	path := filepath.Join(t.TempDir(), "upload.bin")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address, received := fakeClamd(t, tt.reply)
+			scanner, err := NewClamdScanner(address)
+			if err != nil {
+				t.Fatalf("NewClamdScanner: %v", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			result, err := scanner.Scan(ctx, path)
+
+			if data := <-received; !bytes.Equal(data, content) {
+				t.Errorf("clamd received %d bytes, want %d", len(data), len(content))
+			}
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Scan() = %+v, want an error", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+			if result.Verdict != tt.verdict || result.Signature != tt.signature {
+				t.Errorf("Scan() = %q %q, want %q %q", result.Verdict, result.Signature, tt.verdict, tt.signature)
+			}
+		})
+	}
+}
+
+func TestNewClamdScannerAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		wantErr bool
+	}{
+		{address: "tcp://127.0.0.1:3310"},
+		{address: "unix:///run/clamav/clamd.ctl"},
+		{address: "tcp://", wantErr: true},
+		{address: "unix://", wantErr: true},
+		{address: "127.0.0.1:3310", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		_, err := NewClamdScanner(tt.address)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NewClamdScanner(%q) error = %v, want error %v", tt.address, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"simple-server/src/backend/config"
+	"simple-server/src/backend/utils"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidSession is returned for tampered, malformed or expired session cookies
+var ErrInvalidSession = errors.New("invalid or expired session")
+
+type SessionService struct {
+	secret    []byte
+	ttl       time.Duration
+	stateFile string
+	mu        sync.Mutex
+	// revoked maps the IDs of logged-out sessions to their expiry and is persisted
+	revoked map[string]int64
+}
+
+// sessionPayload is the signed content of a session cookie
+type sessionPayload struct {
+	ID        string `json:"i"`
+	Username  string `json:"u"`
+	ExpiresAt int64  `json:"e"`
+}
+
+func NewSessionService(cfg *config.Config) (*SessionService, error) {
+	secret := []byte(cfg.Auth.SessionSecret)
+	if len(secret) == 0 {
+		var err error
+		secret, err = utils.LoadOrCreateSecret(filepath.Join(cfg.Storage.DataDir, "session.key"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ttl := cfg.Auth.SessionTTL
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+
+	ss := &SessionService{
+		secret:    secret,
+		ttl:       ttl,
+		stateFile: filepath.Join(cfg.Storage.DataDir, "revoked-sessions.json"),
+		revoked:   make(map[string]int64),
+	}
+	if err := utils.ReadJSONFile(ss.stateFile, &ss.revoked); err != nil {
+		return nil, err
+	}
+
+	return ss, nil
+}
+
+// TTL returns the session lifetime
+func (ss *SessionService) TTL() time.Duration {
+	return ss.ttl
+}
+
+// Create builds a signed session value for a user
+func (ss *SessionService) Create(username string) (string, time.Time, error) {
+	id, err := utils.RandomToken(16)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiresAt := time.Now().Add(ss.ttl)
+	payload, _ := json.Marshal(sessionPayload{
+		ID:        id,
+		Username:  username,
+		ExpiresAt: expiresAt.Unix(),
+	})
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + ss.sign(encoded), expiresAt, nil
+}
+
+// Verify checks the signature, expiry and revocation of a session value and returns the username
+func (ss *SessionService) Verify(value string) (string, error) {
+	payload, err := ss.parse(value)
+	if err != nil {
+		return "", err
+	}
+
+	ss.mu.Lock()
+	_, revoked := ss.revoked[payload.ID]
+	ss.mu.Unlock()
+	if revoked {
+		return "", ErrInvalidSession
+	}
+
+	return payload.Username, nil
+}
+
+// Revoke invalidates a session value before it expires, e.g. on logout
+func (ss *SessionService) Revoke(value string) error {
+	payload, err := ss.parse(value)
+	if err != nil {
+		return err
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	// Expired sessions no longer need to be remembered
+	now := time.Now().Unix()
+	for id, expiresAt := range ss.revoked {
+		if now > expiresAt {
+			delete(ss.revoked, id)
+		}
+	}
+	ss.revoked[payload.ID] = payload.ExpiresAt
+	return utils.WriteJSONFile(ss.stateFile, ss.revoked)
+}
+
+// parse checks the signature and expiry of a session value and decodes it
+func (ss *SessionService) parse(value string) (*sessionPayload, error) {
+	encoded, signature, ok := strings.Cut(value, ".")
+	if !ok {
+		return nil, ErrInvalidSession
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(ss.sign(encoded))) {
+		return nil, ErrInvalidSession
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidSession
+	}
+
+	var payload sessionPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, ErrInvalidSession
+	}
+
+	// Sessions without an ID predate revocation and cannot be logged out
+	if payload.ID == "" || time.Now().Unix() > payload.ExpiresAt {
+		return nil, ErrInvalidSession
+	}
+
+	return &payload, nil
+}
+
+// sign computes the HMAC signature of a value
+func (ss *SessionService) sign(value string) string {
+	mac := hmac.New(sha256.New, ss.secret)
+	mac.Write([]byte("session:" + value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"simple-server/src/backend/config"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSessionService(t *testing.T, dataDir, secret string) *SessionService {
+	t.Helper()
+
+	cfg := &config.Config{}
+	cfg.Storage.DataDir = dataDir
+	cfg.Auth.SessionSecret = secret
+	cfg.Auth.SessionTTL = time.Hour
+
+	ss, err := NewSessionService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ss
+}
+
+// signedSession builds a session value with an arbitrary payload
+func signedSession(ss *SessionService, payload sessionPayload) string {
+	data, _ := json.Marshal(payload)
+	encoded := base64.RawURLEncoding.EncodeToString(data)
+	return encoded + "." + ss.sign(encoded)
+}
+
+func TestSessionVerify(t *testing.T) {
+	ss := newTestSessionService(t, t.TempDir(), "secret-one")
+
+	value, expiresAt, err := ss.Create("bob")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if ttl := time.Until(expiresAt); ttl <= 0 || ttl > time.Hour {
+		t.Errorf("session expires in %v, want within the 1h TTL", ttl)
+	}
+	if username, err := ss.Verify(value); err != nil || username != "bob" {
+		t.Fatalf("Verify = %q, %v", username, err)
+	}
+
+	encoded, signature, _ := strings.Cut(value, ".")
+	payload, _ := base64.RawURLEncoding.DecodeString(encoded)
+	forged := base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(payload), `"bob"`, `"root"`, 1)))
+
+	future := time.Now().Add(time.Hour).Unix()
+	shares := &ShareService{secret: []byte("secret-one")}
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{name: "changed payload", value: forged + "." + signature},
+		{name: "changed signature", value: encoded + "." + strings.Repeat("A", len(signature))},
+		{name: "missing signature", value: encoded},
+		{name: "other secret", value: signedSession(newTestSessionService(t, t.TempDir(), "secret-two"), sessionPayload{ID: "x", Username: "bob", ExpiresAt: future})},
+		{name: "share signature", value: encoded + "." + shares.signValue(encoded)},
+		{name: "expired", value: signedSession(ss, sessionPayload{ID: "x", Username: "bob", ExpiresAt: time.Now().Add(-time.Minute).Unix()})},
+		{name: "without ID", value: signedSession(ss, sessionPayload{Username: "bob", ExpiresAt: future})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ss.Verify(tt.value); !errors.Is(err, ErrInvalidSession) {
+				t.Errorf("Verify error = %v, want %v", err, ErrInvalidSession)
+			}
+		})
+	}
+}
+
+func TestSessionRevoke(t *testing.T) {
+	dataDir := t.TempDir()
+	ss := newTestSessionService(t, dataDir, "secret")
+
+	value, _, _ := ss.Create("bob")
+	other, _, _ := ss.Create("bob")
+	if err := ss.Revoke(value); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+
+	if _, err := ss.Verify(value); !errors.Is(err, ErrInvalidSession) {
+		t.Errorf("Verify of a revoked session error = %v, want %v", err, ErrInvalidSession)
+	}
+	if _, err := ss.Verify(other); err != nil {
+		t.Errorf("Verify of another session of the user: %v", err)
+	}
+
+	// Revocations survive a restart
+	restarted := newTestSessionService(t, dataDir, "secret")
+	if _, err := restarted.Verify(value); !errors.Is(err, ErrInvalidSession) {
+		t.Errorf("Verify after restart error = %v, want %v", err, ErrInvalidSession)
+	}
+
+	if err := ss.Revoke("garbage"); !errors.Is(err, ErrInvalidSession) {
+		t.Errorf("Revoke of an invalid value error = %v, want %v", err, ErrInvalidSession)
+	}
+}
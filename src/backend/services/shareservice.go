@@ -0,0 +1,289 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"simple-server/src/backend/config"
+	"simple-server/src/backend/utils"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Share link roots, i.e. the directories a shared path is relative to
+const (
+	ShareRootPrivate  = "private"
+	ShareRootIncoming = "incoming"
+)
+
+var (
+	ErrInvalidShareLink = errors.New("invalid share link")
+	ErrShareLinkExpired = errors.New("share link expired")
+	ErrShareLinkUsedUp  = errors.New("share link download limit reached")
+	ErrShareNotFound    = errors.New("share link not found")
+)
+
+type ShareLink struct {
+	ID           string    `json:"id"`
+	Root         string    `json:"root"`
+	Path         string    `json:"path"`
+	CreatedBy    string    `json:"createdBy"`
+	CreatedAt    time.Time `json:"createdAt"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+	MaxDownloads int       `json:"maxDownloads,omitempty"`
+	Downloads    int       `json:"downloads"`
+}
+
+// sharePayload is the signed content of a share token
+type sharePayload struct {
+	ID           string `json:"i"`
+	Root         string `json:"r"`
+	Path         string `json:"p"`
+	ExpiresAt    int64  `json:"e"`
+	MaxDownloads int    `json:"n,omitempty"`
+}
+
+type ShareService struct {
+	config    *config.Config
+	secret    []byte
+	stateFile string
+	mu        sync.Mutex
+	links     map[string]*ShareLink
+}
+
+func NewShareService(cfg *config.Config) (*ShareService, error) {
+	secret := []byte(cfg.Sharing.Secret)
+	if len(secret) == 0 {
+		var err error
+		secret, err = utils.LoadOrCreateSecret(filepath.Join(cfg.Storage.DataDir, "share.key"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ss := &ShareService{
+		config:    cfg,
+		secret:    secret,
+		stateFile: filepath.Join(cfg.Storage.DataDir, "shares.json"),
+		links:     make(map[string]*ShareLink),
+	}
+
+	var links []*ShareLink
+	if err := utils.ReadJSONFile(ss.stateFile, &links); err != nil {
+		return nil, err
+	}
+	for _, link := range links {
+		ss.links[link.ID] = link
+	}
+
+	return ss, nil
+}
+
+// RootDir returns the directory a share root refers to
+func (ss *ShareService) RootDir(root string) (string, bool) {
+	switch root {
+	case ShareRootPrivate:
+		return ss.config.Storage.PrivateDir, true
+	case ShareRootIncoming:
+		return ss.config.Storage.IncomingDir, true
+	default:
+		return "", false
+	}
+}
+
+// Create mints a share link for a file below a share root
+func (ss *ShareService) Create(root, relativePath string, ttl time.Duration, maxDownloads int, createdBy string) (*ShareLink, string, error) {
+	rootDir, ok := ss.RootDir(root)
+	if !ok {
+		return nil, "", ErrInvalidShareLink
+	}
+
+	safePath := utils.SanitizePath(relativePath)
+	if safePath == "" || !utils.IsValidPath(rootDir, safePath) {
+		return nil, "", os.ErrInvalid
+	}
+
+	info, err := os.Stat(filepath.Join(rootDir, safePath))
+	if err != nil {
+		return nil, "", err
+	}
+	if info.IsDir() {
+		return nil, "", os.ErrInvalid
+	}
+
+	if ttl <= 0 {
+		ttl = ss.config.Sharing.DefaultTTL
+	}
+	if ss.config.Sharing.MaxTTL > 0 && ttl > ss.config.Sharing.MaxTTL {
+		ttl = ss.config.Sharing.MaxTTL
+	}
+	if maxDownloads < 0 {
+		maxDownloads = 0
+	}
+
+	id, err := utils.RandomToken(8)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	link := &ShareLink{
+		ID:           id,
+		Root:         root,
+		Path:         filepath.ToSlash(safePath),
+		CreatedBy:    createdBy,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(ttl),
+		MaxDownloads: maxDownloads,
+	}
+
+	ss.mu.Lock()
+	ss.links[id] = link
+	err = ss.saveLocked()
+	ss.mu.Unlock()
+	if err != nil {
+		return nil, "", err
+	}
+
+	return link, ss.sign(link), nil
+}
+
+// Resolve verifies a share token and returns the link and the full path of the shared file.
+// The download counter is not touched; call ClaimDownload before serving the file.
+func (ss *ShareService) Resolve(token string) (*ShareLink, string, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(ss.signValue(encoded))) {
+		return nil, "", ErrInvalidShareLink
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, "", ErrInvalidShareLink
+	}
+	var payload sharePayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, "", ErrInvalidShareLink
+	}
+
+	if time.Now().Unix() > payload.ExpiresAt {
+		return nil, "", ErrShareLinkExpired
+	}
+
+	ss.mu.Lock()
+	link, ok := ss.links[payload.ID]
+	var snapshot ShareLink
+	if ok {
+		snapshot = *link
+	}
+	ss.mu.Unlock()
+
+	// Revoked links are removed from the state file
+	if !ok {
+		return nil, "", ErrInvalidShareLink
+	}
+	if snapshot.MaxDownloads > 0 && snapshot.Downloads >= snapshot.MaxDownloads {
+		return nil, "", ErrShareLinkUsedUp
+	}
+
+	rootDir, ok := ss.RootDir(payload.Root)
+	if !ok || !utils.IsValidPath(rootDir, payload.Path) {
+		return nil, "", ErrInvalidShareLink
+	}
+
+	return &snapshot, filepath.Join(rootDir, utils.SanitizePath(filepath.FromSlash(payload.Path))), nil
+}
+
+// ClaimDownload counts a download of a share link if the link is still
+// valid. Expiry and the download limit are checked and the counter updated
+// in one step, so concurrent requests cannot exceed the limit.
+func (ss *ShareService) ClaimDownload(id string) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	link, ok := ss.links[id]
+	if !ok {
+		return ErrShareNotFound
+	}
+	if time.Now().After(link.ExpiresAt) {
+		return ErrShareLinkExpired
+	}
+	if link.MaxDownloads > 0 && link.Downloads >= link.MaxDownloads {
+		return ErrShareLinkUsedUp
+	}
+	link.Downloads++
+	return ss.saveLocked()
+}
+
+// List returns the active share links, limited to one creator unless createdBy is empty
+func (ss *ShareService) List(createdBy string) []ShareLink {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	var links []ShareLink
+	for _, link := range ss.links {
+		if createdBy == "" || link.CreatedBy == createdBy {
+			links = append(links, *link)
+		}
+	}
+
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].CreatedAt.Before(links[j].CreatedAt)
+	})
+	return links
+}
+
+// Revoke invalidates a share link. Only the creator or an admin (empty createdBy) may revoke it.
+func (ss *ShareService) Revoke(id, createdBy string) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	link, ok := ss.links[id]
+	if !ok || (createdBy != "" && link.CreatedBy != createdBy) {
+		return ErrShareNotFound
+	}
+
+	delete(ss.links, id)
+	return ss.saveLocked()
+}
+
+// saveLocked drops expired links and writes the state file. Callers must hold ss.mu.
+func (ss *ShareService) saveLocked() error {
+	now := time.Now()
+	links := make([]*ShareLink, 0, len(ss.links))
+	for id, link := range ss.links {
+		if now.After(link.ExpiresAt) {
+			delete(ss.links, id)
+			continue
+		}
+		links = append(links, link)
+	}
+
+	return utils.WriteJSONFile(ss.stateFile, links)
+}
+
+// sign builds the share token of a link
+func (ss *ShareService) sign(link *ShareLink) string {
+	payload, _ := json.Marshal(sharePayload{
+		ID:           link.ID,
+		Root:         link.Root,
+		Path:         link.Path,
+		ExpiresAt:    link.ExpiresAt.Unix(),
+		MaxDownloads: link.MaxDownloads,
+	})
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + ss.signValue(encoded)
+}
+
+// signValue computes the HMAC signature of an encoded payload
+func (ss *ShareService) signValue(value string) string {
+	mac := hmac.New(sha256.New, ss.secret)
+	mac.Write([]byte("share:" + value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"simple-server/src/backend/config"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestShareService(t *testing.T, secret string) *ShareService {
+	t.Helper()
+
+	root := t.TempDir()
+	cfg := &config.Config{
+		Storage: config.StorageConfig{
+			UploadDir:   filepath.Join(root, "files"),
+			IncomingDir: filepath.Join(root, "files", "incoming"),
+			PrivateDir:  filepath.Join(root, "files", "private-files"),
+			DataDir:     filepath.Join(root, "data"),
+		},
+		Sharing: config.SharingConfig{Enabled: true, Secret: secret, DefaultTTL: time.Hour, MaxTTL: 24 * time.Hour},
+	}
+	for _, name := range []string{"report.pdf", "other.pdf"} {
+		path := filepath.Join(cfg.Storage.PrivateDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ss, err := NewShareService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ss
+}
+
+func TestShareTokenSignature(t *testing.T) {
+	ss := newTestShareService(t, "secret-one")
+
+	link, token, err := ss.Create(ShareRootPrivate, "report.pdf", 0, 0, "bob")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, path, err := ss.Resolve(token); err != nil || filepath.Base(path) != "report.pdf" {
+		t.Fatalf("Resolve = %q, %v", path, err)
+	}
+
+	encoded, signature, _ := strings.Cut(token, ".")
+	payload, _ := base64.RawURLEncoding.DecodeString(encoded)
+	forged := base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(payload), "report.pdf", "other.pdf", 1)))
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "changed payload", token: forged + "." + signature},
+		{name: "changed signature", token: encoded + "." + strings.Repeat("A", len(signature))},
+		{name: "missing signature", token: encoded},
+		{name: "empty", token: ""},
+		{name: "other secret", token: newTestShareService(t, "secret-two").sign(link)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := ss.Resolve(tt.token); !errors.Is(err, ErrInvalidShareLink) {
+				t.Errorf("Resolve error = %v, want %v", err, ErrInvalidShareLink)
+			}
+		})
+	}
+}
+
+func TestShareTokenExpiryAndRevocation(t *testing.T) {
+	ss := newTestShareService(t, "secret")
+
+	link, token, err := ss.Create(ShareRootPrivate, "report.pdf", 48*time.Hour, 0, "bob")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if ttl := link.ExpiresAt.Sub(link.CreatedAt); ttl != 24*time.Hour {
+		t.Errorf("TTL = %v, want it capped at 24h", ttl)
+	}
+
+	expired := *link
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	if _, _, err := ss.Resolve(ss.sign(&expired)); !errors.Is(err, ErrShareLinkExpired) {
+		t.Errorf("Resolve of an expired token error = %v, want %v", err, ErrShareLinkExpired)
+	}
+
+	if err := ss.Revoke(link.ID, "carol"); !errors.Is(err, ErrShareNotFound) {
+		t.Errorf("Revoke by another user error = %v, want %v", err, ErrShareNotFound)
+	}
+	if err := ss.Revoke(link.ID, "bob"); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if _, _, err := ss.Resolve(token); !errors.Is(err, ErrInvalidShareLink) {
+		t.Errorf("Resolve of a revoked token error = %v, want %v", err, ErrInvalidShareLink)
+	}
+}
+
+func TestShareClaimDownloadLimit(t *testing.T) {
+	ss := newTestShareService(t, "secret")
+
+	link, token, err := ss.Create(ShareRootPrivate, "report.pdf", 0, 5, "bob")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	claimed := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ss.ClaimDownload(link.ID) == nil {
+				mu.Lock()
+				claimed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if claimed != 5 {
+		t.Errorf("%d downloads claimed, want 5", claimed)
+	}
+	if err := ss.ClaimDownload(link.ID); !errors.Is(err, ErrShareLinkUsedUp) {
+		t.Errorf("ClaimDownload error = %v, want %v", err, ErrShareLinkUsedUp)
+	}
+	if _, _, err := ss.Resolve(token); !errors.Is(err, ErrShareLinkUsedUp) {
+		t.Errorf("Resolve error = %v, want %v", err, ErrShareLinkUsedUp)
+	}
+	if err := ss.ClaimDownload("unknown"); !errors.Is(err, ErrShareNotFound) {
+		t.Errorf("ClaimDownload of an unknown link error = %v, want %v", err, ErrShareNotFound)
+	}
+}
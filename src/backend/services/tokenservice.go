@@ -0,0 +1,215 @@
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"simple-server/src/backend/config"
+	"simple-server/src/backend/utils"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// API token scopes
+const (
+	ScopeRead   = "read"
+	ScopeUpload = "upload"
+	ScopeDelete = "delete"
+	ScopeAdmin  = "admin"
+)
+
+// tokenPrefix marks API tokens so they are easy to spot in scripts and logs
+const tokenPrefix = "ssg_"
+
+// lastUsedInterval limits how often token usage is written to disk
+const lastUsedInterval = time.Minute
+
+var (
+	ErrInvalidToken  = errors.New("invalid or expired token")
+	ErrInvalidScope  = errors.New("invalid token scope")
+	ErrTokenNotFound = errors.New("token not found")
+)
+
+type APIToken struct {
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	Owner        string     `json:"owner"`
+	Hash         string     `json:"hash,omitempty"`
+	Scopes       []string   `json:"scopes"`
+	PathPrefixes []string   `json:"pathPrefixes,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	ExpiresAt    *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt   *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+type TokenService struct {
+	stateFile string
+	mu        sync.Mutex
+	tokens    map[string]*APIToken
+}
+
+func NewTokenService(cfg *config.Config) (*TokenService, error) {
+	ts := &TokenService{
+		stateFile: filepath.Join(cfg.Storage.DataDir, "tokens.json"),
+		tokens:    make(map[string]*APIToken),
+	}
+
+	var tokens []*APIToken
+	if err := utils.ReadJSONFile(ts.stateFile, &tokens); err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		ts.tokens[token.ID] = token
+	}
+
+	return ts, nil
+}
+
+// IsValidScope checks if a scope name is known
+func IsValidScope(scope string) bool {
+	switch scope {
+	case ScopeRead, ScopeUpload, ScopeDelete, ScopeAdmin:
+		return true
+	default:
+		return false
+	}
+}
+
+// Create generates a new token. The plain token is only returned here; just its hash is stored.
+func (ts *TokenService) Create(name, owner string, scopes, pathPrefixes []string, ttl time.Duration) (*APIToken, string, error) {
+	if len(scopes) == 0 {
+		return nil, "", ErrInvalidScope
+	}
+	for _, scope := range scopes {
+		if !IsValidScope(scope) {
+			return nil, "", ErrInvalidScope
+		}
+	}
+
+	id, err := utils.RandomToken(6)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := utils.RandomToken(24)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var prefixes []string
+	for _, prefix := range pathPrefixes {
+		if clean := filepath.ToSlash(utils.SanitizePath(prefix)); clean != "" {
+			prefixes = append(prefixes, clean)
+		}
+	}
+
+	token := &APIToken{
+		ID:           id,
+		Name:         name,
+		Owner:        owner,
+		Hash:         hashToken(secret),
+		Scopes:       scopes,
+		PathPrefixes: prefixes,
+		CreatedAt:    time.Now(),
+	}
+	if ttl > 0 {
+		expiresAt := token.CreatedAt.Add(ttl)
+		token.ExpiresAt = &expiresAt
+	}
+
+	ts.mu.Lock()
+	ts.tokens[id] = token
+	err = ts.saveLocked()
+	ts.mu.Unlock()
+	if err != nil {
+		return nil, "", err
+	}
+
+	return token, tokenPrefix + id + "_" + secret, nil
+}
+
+// Verify checks a plain token and returns its record
+func (ts *TokenService) Verify(plain string) (*APIToken, error) {
+	rest, ok := strings.CutPrefix(plain, tokenPrefix)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	id, secret, ok := strings.Cut(rest, "_")
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	token, ok := ts.tokens[id]
+	if !ok || subtle.ConstantTimeCompare([]byte(token.Hash), []byte(hashToken(secret))) != 1 {
+		return nil, ErrInvalidToken
+	}
+
+	now := time.Now()
+	if token.ExpiresAt != nil && now.After(*token.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > lastUsedInterval {
+		token.LastUsedAt = &now
+		// Usage tracking is best effort
+		_ = ts.saveLocked()
+	}
+
+	snapshot := *token
+	return &snapshot, nil
+}
+
+// List returns the tokens of an owner, or all tokens if owner is empty
+func (ts *TokenService) List(owner string) []APIToken {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	var tokens []APIToken
+	for _, token := range ts.tokens {
+		if owner == "" || token.Owner == owner {
+			snapshot := *token
+			snapshot.Hash = ""
+			tokens = append(tokens, snapshot)
+		}
+	}
+
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.Before(tokens[j].CreatedAt)
+	})
+	return tokens
+}
+
+// Revoke deletes a token. A non-empty owner restricts revocation to that owner's tokens.
+func (ts *TokenService) Revoke(id, owner string) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	token, ok := ts.tokens[id]
+	if !ok || (owner != "" && token.Owner != owner) {
+		return ErrTokenNotFound
+	}
+
+	delete(ts.tokens, id)
+	return ts.saveLocked()
+}
+
+// saveLocked writes the state file. Callers must hold ts.mu.
+func (ts *TokenService) saveLocked() error {
+	tokens := make([]*APIToken, 0, len(ts.tokens))
+	for _, token := range ts.tokens {
+		tokens = append(tokens, token)
+	}
+	return utils.WriteJSONFile(ts.stateFile, tokens)
+}
+
+// hashToken hashes the secret part of a token for storage
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"simple-server/src/backend/config"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestTokenService(t *testing.T, dataDir string) *TokenService {
+	t.Helper()
+
+	cfg := &config.Config{}
+	cfg.Storage.DataDir = dataDir
+	ts, err := NewTokenService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+func TestTokenStoredHashed(t *testing.T) {
+	dataDir := t.TempDir()
+	ts := newTestTokenService(t, dataDir)
+
+	token, plain, err := ts.Create("ci", "bob", []string{ScopeRead, ScopeUpload}, []string{"/builds/../builds/", ""}, 0)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if !strings.HasPrefix(plain, tokenPrefix+token.ID+"_") {
+		t.Errorf("token %q lacks the %q prefix and ID", plain, tokenPrefix)
+	}
+	if len(token.PathPrefixes) != 1 || token.PathPrefixes[0] != "builds" {
+		t.Errorf("path prefixes = %q, want [builds]", token.PathPrefixes)
+	}
+
+	secret := strings.TrimPrefix(plain, tokenPrefix+token.ID+"_")
+	state, err := os.ReadFile(filepath.Join(dataDir, "tokens.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(state), secret) {
+		t.Error("state file contains the plain token secret")
+	}
+	if !strings.Contains(string(state), hashToken(secret)) {
+		t.Error("state file lacks the token hash")
+	}
+	for _, listed := range ts.List("bob") {
+		if listed.Hash != "" {
+			t.Error("List returned a token hash")
+		}
+	}
+
+	// Tokens survive a restart
+	verified, err := newTestTokenService(t, dataDir).Verify(plain)
+	if err != nil {
+		t.Fatalf("Verify after restart: %v", err)
+	}
+	if verified.Owner != "bob" || len(verified.Scopes) != 2 {
+		t.Errorf("Verify = %+v", verified)
+	}
+}
+
+func TestTokenVerify(t *testing.T) {
+	ts := newTestTokenService(t, t.TempDir())
+
+	token, plain, err := ts.Create("ci", "bob", []string{ScopeRead}, nil, time.Hour)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	other, otherPlain, _ := ts.Create("deploy", "carol", []string{ScopeRead}, nil, 0)
+	expired, expiredPlain, _ := ts.Create("old", "bob", []string{ScopeRead}, nil, time.Hour)
+	past := time.Now().Add(-time.Minute)
+	ts.tokens[expired.ID].ExpiresAt = &past
+
+	secret := strings.TrimPrefix(plain, tokenPrefix+token.ID+"_")
+	otherSecret := strings.TrimPrefix(otherPlain, tokenPrefix+other.ID+"_")
+
+	tests := []struct {
+		name  string
+		plain string
+	}{
+		{name: "wrong secret", plain: tokenPrefix + token.ID + "_" + strings.Repeat("x", len(secret))},
+		{name: "secret of another token", plain: tokenPrefix + token.ID + "_" + otherSecret},
+		{name: "unknown ID", plain: tokenPrefix + "nope_" + secret},
+		{name: "missing prefix", plain: token.ID + "_" + secret},
+		{name: "missing secret", plain: tokenPrefix + token.ID},
+		{name: "expired", plain: expiredPlain},
+	}
+
+	if _, err := ts.Verify(plain); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ts.Verify(tt.plain); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Verify error = %v, want %v", err, ErrInvalidToken)
+			}
+		})
+	}
+
+	if err := ts.Revoke(token.ID, "carol"); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("Revoke by another owner error = %v, want %v", err, ErrTokenNotFound)
+	}
+	if err := ts.Revoke(token.ID, "bob"); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if _, err := ts.Verify(plain); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Verify of a revoked token error = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestTokenScopes(t *testing.T) {
+	ts := newTestTokenService(t, t.TempDir())
+
+	for _, scopes := range [][]string{nil, {}, {"write"}, {ScopeRead, "Admin"}} {
+		if _, _, err := ts.Create("x", "bob", scopes, nil, 0); !errors.Is(err, ErrInvalidScope) {
+			t.Errorf("Create with scopes %q error = %v, want %v", scopes, err, ErrInvalidScope)
+		}
+	}
+	for _, scope := range []string{ScopeRead, ScopeUpload, ScopeDelete, ScopeAdmin} {
+		if _, _, err := ts.Create("x", "bob", []string{scope}, nil, 0); err != nil {
+			t.Errorf("Create with scope %q: %v", scope, err)
+		}
+	}
+}
@@ -0,0 +1,349 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"simple-server/src/backend/config"
+	"simple-server/src/backend/utils"
+	"sync"
+	"time"
+)
+
+var (
+	ErrTusUploadNotFound  = errors.New("upload not found")
+	ErrTusUploadExpired   = errors.New("upload expired")
+	ErrTusOffsetMismatch  = errors.New("upload offset does not match")
+	ErrTusUploadLocked    = errors.New("upload is being written by another request")
+	ErrTusUploadCompleted = errors.New("upload already completed")
+)
+
+// TusUpload is a resumable upload in progress. Its data is appended to a
+// partial file below the incoming directory; the offset is the size of that file.
+type TusUpload struct {
+	ID        string            `json:"id"`
+	Filename  string            `json:"filename"`
+	Length    int64             `json:"length"`
+	Offset    int64             `json:"-"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Owner     string            `json:"owner,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+
+	// lock serializes writes to the partial file
+	lock sync.Mutex
+	// reservation holds quota for the whole length until the upload is
+	// committed or removed; nil without quotas and after a restart
+	reservation *QuotaReservation
+}
+
+type TusService struct {
+	config        *config.Config
+	uploadService *UploadService
+	stateFile     string
+	partDir       string
+	mu            sync.Mutex
+	uploads       map[string]*TusUpload
+}
+
+func NewTusService(cfg *config.Config, uploadService *UploadService) (*TusService, error) {
+	ts := &TusService{
+		config:        cfg,
+		uploadService: uploadService,
+		stateFile:     filepath.Join(cfg.Storage.DataDir, "tus.json"),
+		partDir:       filepath.Join(cfg.Storage.IncomingDir, ".tus"),
+		uploads:       make(map[string]*TusUpload),
+	}
+
+	if err := os.MkdirAll(ts.partDir, 0700); err != nil {
+		return nil, err
+	}
+
+	var uploads []*TusUpload
+	if err := utils.ReadJSONFile(ts.stateFile, &uploads); err != nil {
+		return nil, err
+	}
+	for _, upload := range uploads {
+		ts.uploads[upload.ID] = upload
+	}
+
+	return ts, nil
+}
+
+// Create registers a new upload after checking its name and length, and
+// reserves quota for the length so that partial files cannot outgrow it
+func (ts *TusService) Create(filename string, length int64, metadata map[string]string, owner string) (*TusUpload, error) {
+	req := UploadRequest{Filename: filename, Size: length, Uploader: owner}
+	if err := ts.uploadService.Validate(req); err != nil {
+		return nil, err
+	}
+
+	id, err := utils.RandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	reservation, err := ts.uploadService.reserve(req, ts.uploadService.destDir(req), length)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	upload := &TusUpload{
+		ID:        id,
+		Filename:  utils.SanitizeFilename(filename),
+		Length:    length,
+		Metadata:  metadata,
+		Owner:     owner,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ts.config.Tus.Expiration),
+
+		reservation: reservation,
+	}
+
+	f, err := os.OpenFile(ts.partPath(id), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		reservation.Release()
+		return nil, err
+	}
+	f.Close()
+
+	ts.mu.Lock()
+	ts.uploads[id] = upload
+	err = ts.saveLocked()
+	ts.mu.Unlock()
+	if err != nil {
+		ts.remove(id)
+		return nil, err
+	}
+
+	return ts.snapshot(upload), nil
+}
+
+// Get returns an upload with its current offset
+func (ts *TusService) Get(id string) (*TusUpload, error) {
+	upload, err := ts.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	return ts.snapshot(upload), nil
+}
+
+// Write appends data at offset. When the upload reaches its length it is
+// moved into the incoming directory and the stored file is returned; if the
+// file is refused at that point, the upload is removed and must start over.
+// Bytes received before a dropped connection are kept, so clients can resume.
+func (ts *TusService) Write(id string, offset int64, src io.Reader) (*TusUpload, *StoredFile, error) {
+	upload, err := ts.lookup(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !upload.lock.TryLock() {
+		return nil, nil, ErrTusUploadLocked
+	}
+	defer upload.lock.Unlock()
+
+	f, err := os.OpenFile(ts.partPath(id), os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.Size() != offset {
+		return nil, nil, ErrTusOffsetMismatch
+	}
+	if offset == upload.Length && upload.Length > 0 {
+		return nil, nil, ErrTusUploadCompleted
+	}
+
+	// Reservations are not persisted, so uploads resumed after a restart claim theirs again
+	if upload.reservation == nil {
+		req := UploadRequest{Filename: upload.Filename, Size: upload.Length, Uploader: upload.Owner}
+		reservation, err := ts.uploadService.reserve(req, ts.uploadService.destDir(req), upload.Length)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := reservation.settle(offset); err != nil {
+			reservation.Release()
+			return nil, nil, err
+		}
+		upload.reservation = reservation
+	}
+
+	// Read one byte past the declared length to detect oversized chunks,
+	// which are discarded as a whole
+	remaining := upload.Length - offset
+	written, copyErr := io.Copy(f, io.LimitReader(src, remaining+1))
+	if written > remaining {
+		f.Truncate(offset)
+		written = 0
+		copyErr = ErrFileTooLarge
+	}
+	// The partial file never outgrows the reserved length, so this only
+	// moves bytes from pending to written
+	upload.reservation.settle(offset + written)
+
+	ts.mu.Lock()
+	upload.ExpiresAt = time.Now().Add(ts.config.Tus.Expiration)
+	saveErr := ts.saveLocked()
+	ts.mu.Unlock()
+
+	if copyErr != nil {
+		return nil, nil, copyErr
+	}
+	if saveErr != nil {
+		return nil, nil, saveErr
+	}
+
+	current := ts.snapshot(upload)
+	current.Offset = offset + written
+	if current.Offset < upload.Length {
+		return current, nil, nil
+	}
+
+	f.Close()
+	stored, err := ts.uploadService.Commit(ts.partPath(id), UploadRequest{
+		Filename:    upload.Filename,
+		Size:        upload.Length,
+		Uploader:    upload.Owner,
+		Reservation: upload.reservation,
+	})
+	// A refused upload is discarded as well; otherwise it would stay complete
+	// and every retry would be answered with ErrTusUploadCompleted
+	ts.remove(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return current, stored, nil
+}
+
+// Terminate discards an upload and its data
+func (ts *TusService) Terminate(id string) error {
+	upload, err := ts.lookup(id)
+	if err != nil {
+		return err
+	}
+
+	if !upload.lock.TryLock() {
+		return ErrTusUploadLocked
+	}
+	defer upload.lock.Unlock()
+
+	return ts.remove(id)
+}
+
+// Cleanup removes expired uploads and partial files no upload refers to.
+// It returns the number of uploads removed.
+func (ts *TusService) Cleanup() (int, error) {
+	now := time.Now()
+
+	ts.mu.Lock()
+	var expired []*TusUpload
+	for _, upload := range ts.uploads {
+		if now.After(upload.ExpiresAt) {
+			expired = append(expired, upload)
+		}
+	}
+	ts.mu.Unlock()
+
+	// Uploads still being written are picked up by the next run
+	removed := 0
+	for _, upload := range expired {
+		if !upload.lock.TryLock() {
+			continue
+		}
+		if err := ts.remove(upload.ID); err == nil {
+			removed++
+		}
+		upload.lock.Unlock()
+	}
+
+	// Partial files left behind by a crash between creating the file and saving state
+	entries, err := os.ReadDir(ts.partDir)
+	if err != nil {
+		return removed, err
+	}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	for _, entry := range entries {
+		if _, ok := ts.uploads[entry.Name()]; ok {
+			continue
+		}
+		if info, err := entry.Info(); err == nil && now.Sub(info.ModTime()) > ts.config.Tus.Expiration {
+			os.Remove(filepath.Join(ts.partDir, entry.Name()))
+		}
+	}
+
+	return removed, nil
+}
+
+// lookup returns an upload that has not expired
+func (ts *TusService) lookup(id string) (*TusUpload, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	upload, ok := ts.uploads[id]
+	if !ok {
+		return nil, ErrTusUploadNotFound
+	}
+	if time.Now().After(upload.ExpiresAt) {
+		return nil, ErrTusUploadExpired
+	}
+	return upload, nil
+}
+
+// snapshot copies an upload and fills in its offset from the partial file
+func (ts *TusService) snapshot(upload *TusUpload) *TusUpload {
+	ts.mu.Lock()
+	current := &TusUpload{
+		ID:        upload.ID,
+		Filename:  upload.Filename,
+		Length:    upload.Length,
+		Metadata:  upload.Metadata,
+		Owner:     upload.Owner,
+		CreatedAt: upload.CreatedAt,
+		ExpiresAt: upload.ExpiresAt,
+	}
+	ts.mu.Unlock()
+
+	if info, err := os.Stat(ts.partPath(upload.ID)); err == nil {
+		current.Offset = info.Size()
+	}
+	return current
+}
+
+// remove deletes an upload and its partial file and releases its quota.
+// A committed file is tracked by then, so releasing does not free its bytes.
+func (ts *TusService) remove(id string) error {
+	os.Remove(ts.partPath(id))
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if upload, ok := ts.uploads[id]; ok {
+		upload.reservation.Release()
+		upload.reservation = nil
+	}
+	delete(ts.uploads, id)
+	return ts.saveLocked()
+}
+
+// partPath returns the partial file of an upload
+func (ts *TusService) partPath(id string) string {
+	return filepath.Join(ts.partDir, id)
+}
+
+// saveLocked persists the uploads. Callers must hold ts.mu.
+func (ts *TusService) saveLocked() error {
+	uploads := make([]*TusUpload, 0, len(ts.uploads))
+	for _, upload := range ts.uploads {
+		uploads = append(uploads, upload)
+	}
+	return utils.WriteJSONFile(ts.stateFile, uploads)
+}
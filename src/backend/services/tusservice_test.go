@@ -0,0 +1,182 @@
+package services
+
+import (
+	"errors"
+	"path/filepath"
+	"simple-server/src/backend/config"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestTusService(t *testing.T, perUser int64) (*TusService, *QuotaService) {
+	t.Helper()
+
+	root := t.TempDir()
+	cfg := &config.Config{
+		Storage: config.StorageConfig{
+			UploadDir:     filepath.Join(root, "files"),
+			IncomingDir:   filepath.Join(root, "files", "incoming"),
+			PrivateDir:    filepath.Join(root, "files", "private-files"),
+			DataDir:       filepath.Join(root, "data"),
+			MaxUploadSize: 1 << 20,
+		},
+		Quota: config.QuotaConfig{Enabled: true, PerUser: perUser},
+		Tus:   config.TusConfig{Enabled: true, Expiration: time.Hour},
+	}
+
+	uploadService, err := NewUploadService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tusService, err := NewTusService(cfg, uploadService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	quotaService, err := NewQuotaService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploadService.SetQuota(quotaService)
+	uploadService.AddTracker(quotaService)
+	return tusService, quotaService
+}
+
+func TestTusReservesLength(t *testing.T) {
+	ts, qs := newTestTusService(t, 100)
+
+	first, err := ts.Create("a.txt", 60, nil, "bob")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := ts.Create("b.txt", 60, nil, "bob"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("second Create error = %v, want %v", err, ErrQuotaExceeded)
+	}
+	// Other users have quotas of their own
+	if _, err := ts.Create("c.txt", 60, nil, "carol"); err != nil {
+		t.Fatalf("Create for another user: %v", err)
+	}
+
+	if err := ts.Terminate(first.ID); err != nil {
+		t.Fatalf("Terminate: %v", err)
+	}
+	upload, err := ts.Create("d.txt", 100, nil, "bob")
+	if err != nil {
+		t.Fatalf("Create after Terminate: %v", err)
+	}
+
+	// Chunks stay within the reservation, and the commit claims it
+	if _, _, err := ts.Write(upload.ID, 0, strings.NewReader(strings.Repeat("x", 40))); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	_, stored, err := ts.Write(upload.ID, 40, strings.NewReader(strings.Repeat("x", 60)))
+	if err != nil || stored == nil {
+		t.Fatalf("final Write = %v, %v", stored, err)
+	}
+
+	if used := qs.UserUsage("bob").Used; used != 100 {
+		t.Errorf("bob uses %d bytes, want 100", used)
+	}
+	if room, _ := qs.Room("bob", filepath.Dir(stored.Path)); room != 0 {
+		t.Errorf("bob has %d bytes of room left, want 0", room)
+	}
+	if room, _ := qs.Room("carol", filepath.Dir(stored.Path)); room != 40 {
+		t.Errorf("carol has %d bytes of room left, want 40", room)
+	}
+}
+
+func TestTusCleanupReleasesReservation(t *testing.T) {
+	ts, qs := newTestTusService(t, 100)
+
+	upload, err := ts.Create("a.txt", 100, nil, "bob")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	ts.mu.Lock()
+	ts.uploads[upload.ID].ExpiresAt = time.Now().Add(-time.Second)
+	ts.mu.Unlock()
+	if removed, err := ts.Cleanup(); err != nil || removed != 1 {
+		t.Fatalf("Cleanup = %d, %v, want 1 upload removed", removed, err)
+	}
+
+	if room, _ := qs.Room("bob", ts.config.Storage.IncomingDir); room != 100 {
+		t.Errorf("bob has %d bytes of room after cleanup, want 100", room)
+	}
+}
+
+func TestTusWrite(t *testing.T) {
+	ts, _ := newTestTusService(t, 0)
+
+	upload, err := ts.Create("notes.txt", 10, map[string]string{"filename": "notes.txt"}, "bob")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if _, _, err := ts.Write(upload.ID, 3, strings.NewReader("abc")); !errors.Is(err, ErrTusOffsetMismatch) {
+		t.Errorf("Write at a wrong offset error = %v, want %v", err, ErrTusOffsetMismatch)
+	}
+
+	current, stored, err := ts.Write(upload.ID, 0, strings.NewReader("hello"))
+	if err != nil || stored != nil || current.Offset != 5 {
+		t.Fatalf("first Write = %+v, %v, %v", current, stored, err)
+	}
+
+	// Chunks past the declared length are discarded as a whole
+	if _, _, err := ts.Write(upload.ID, 5, strings.NewReader("world!")); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("oversized Write error = %v, want %v", err, ErrFileTooLarge)
+	}
+	if got, err := ts.Get(upload.ID); err != nil || got.Offset != 5 {
+		t.Fatalf("Get after an oversized chunk = %+v, %v, want offset 5", got, err)
+	}
+
+	_, stored, err = ts.Write(upload.ID, 5, strings.NewReader("world"))
+	if err != nil || stored == nil {
+		t.Fatalf("final Write = %v, %v", stored, err)
+	}
+	if stored.Name != "notes.txt" || stored.Size != 10 {
+		t.Errorf("stored = %+v", stored)
+	}
+	if _, err := ts.Get(upload.ID); !errors.Is(err, ErrTusUploadNotFound) {
+		t.Errorf("Get of a completed upload error = %v, want %v", err, ErrTusUploadNotFound)
+	}
+}
+
+func TestTusRefusedUploadIsRemoved(t *testing.T) {
+	ts, _ := newTestTusService(t, 0)
+	ts.config.Storage.Collision.Policy = config.CollisionReject
+
+	first, _ := ts.Create("same.txt", 2, nil, "bob")
+	second, _ := ts.Create("same.txt", 2, nil, "bob")
+	if _, _, err := ts.Write(first.ID, 0, strings.NewReader("ab")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	if _, _, err := ts.Write(second.ID, 0, strings.NewReader("cd")); !errors.Is(err, ErrFileExists) {
+		t.Fatalf("Write of a taken name error = %v, want %v", err, ErrFileExists)
+	}
+	if _, err := ts.Get(second.ID); !errors.Is(err, ErrTusUploadNotFound) {
+		t.Errorf("Get of a refused upload error = %v, want %v", err, ErrTusUploadNotFound)
+	}
+}
+
+func TestTusStateSurvivesRestart(t *testing.T) {
+	ts, _ := newTestTusService(t, 0)
+
+	upload, _ := ts.Create("a.txt", 4, nil, "bob")
+	if _, _, err := ts.Write(upload.ID, 0, strings.NewReader("ab")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	restarted, err := NewTusService(ts.config, ts.uploadService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := restarted.Get(upload.ID)
+	if err != nil || got.Offset != 2 || got.Owner != "bob" {
+		t.Fatalf("Get after restart = %+v, %v", got, err)
+	}
+	if _, stored, err := restarted.Write(upload.ID, 2, strings.NewReader("cd")); err != nil || stored == nil {
+		t.Fatalf("Write after restart = %v, %v", stored, err)
+	}
+}
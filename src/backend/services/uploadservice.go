@@ -0,0 +1,647 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"simple-server/src/backend/config"
+	"simple-server/src/backend/utils"
+	"strings"
+	"time"
+)
+
+var (
+	ErrFileTooLarge        = errors.New("file too large")
+	ErrExtensionNotAllowed = errors.New("file type not allowed")
+	ErrInvalidFilename     = errors.New("invalid filename")
+	ErrFileExists          = errors.New("file already exists")
+	ErrChecksumMismatch    = errors.New("checksum mismatch")
+	// ErrContentTypeNotAllowed and ErrContentMismatch are wrapped with the detected type
+	ErrContentTypeNotAllowed = errors.New("content type not allowed")
+	ErrContentMismatch       = errors.New("content does not match file extension")
+	// ErrInfected is wrapped with the name of the malware found
+	ErrInfected   = errors.New("malware detected")
+	ErrScanFailed = errors.New("malware scan failed")
+)
+
+// QuarantinedError reports an upload that was moved to quarantine instead of being stored
+type QuarantinedError struct {
+	Reason error
+	Path   string
+}
+
+func (e *QuarantinedError) Error() string {
+	return e.Reason.Error() + " (quarantined)"
+}
+
+func (e *QuarantinedError) Unwrap() error {
+	return e.Reason
+}
+
+// maxRenameAttempts bounds the search for a free name under the rename policy
+const maxRenameAttempts = 10000
+
+type UploadService struct {
+	config   *config.Config
+	trackers []UploadTracker
+	scanner  Scanner
+	quota    *QuotaService
+	// scanFailed is told about files that could not be scanned
+	scanFailed func(filename string, err error)
+}
+
+// UploadTracker is told about every stored upload
+type UploadTracker interface {
+	Track(file *StoredFile, req UploadRequest)
+}
+
+// RemovalTracker is an UploadTracker that is also told when a stored file is moved away or deleted
+type RemovalTracker interface {
+	Untrack(path string)
+}
+
+// UploadRequest describes a file to store
+type UploadRequest struct {
+	Filename string
+	// Dir is the destination directory; defaults to the incoming directory
+	Dir string
+	// Size is the declared size, or -1 if unknown
+	Size int64
+	// AllowedExtensions overrides the configured extension list when set
+	AllowedExtensions []string
+	// Uploader and ClientIP describe who sent the file
+	Uploader string
+	ClientIP string
+	// Expected holds the digests the client sent along, if any
+	Expected Checksums
+	// Approved skips the extension and content checks for files a moderator
+	// accepted, and the uploader's quota, which already counts them
+	Approved bool
+	// Reservation, if set, already holds the quota for the upload; the
+	// caller releases it once the upload is stored or refused
+	Reservation *QuotaReservation
+}
+
+// Checksums are the digests a client expects an upload to have; nil fields are not checked
+type Checksums struct {
+	SHA256 []byte
+	MD5    []byte
+}
+
+// StoredFile describes a stored upload
+type StoredFile struct {
+	Name string `json:"filename"`
+	Path string `json:"-"`
+	Size int64  `json:"size"`
+	// SHA256 is the hex-encoded digest of the stored bytes
+	SHA256 string `json:"sha256"`
+	// Scan is the malware scan verdict, if scanning is enabled
+	Scan *ScanResult `json:"scan,omitempty"`
+}
+
+func NewUploadService(cfg *config.Config) (*UploadService, error) {
+	collision := cfg.Storage.Collision
+	if err := checkCollisionPolicy(collision.Policy, collision.Suffix); err != nil {
+		return nil, err
+	}
+	for _, rule := range collision.Rules {
+		if err := checkCollisionPolicy(rule.Policy, rule.Suffix); err != nil {
+			return nil, fmt.Errorf("collision rule %q: %w", rule.Path, err)
+		}
+	}
+
+	switch cfg.Security.ContentMismatch {
+	case "", config.MismatchReject, config.MismatchQuarantine, config.MismatchAllow:
+	default:
+		return nil, fmt.Errorf("invalid content mismatch policy %q", cfg.Security.ContentMismatch)
+	}
+
+	return &UploadService{
+		config: cfg,
+	}, nil
+}
+
+// AddTracker registers a tracker to be told about stored uploads
+func (us *UploadService) AddTracker(tracker UploadTracker) {
+	us.trackers = append(us.trackers, tracker)
+}
+
+// SetScanner sets the malware scanner run on completed uploads; nil disables
+// scanning. onFailure, if set, is called for each file that could not be scanned.
+func (us *UploadService) SetScanner(scanner Scanner, onFailure func(filename string, err error)) {
+	us.scanner = scanner
+	us.scanFailed = onFailure
+}
+
+// SetQuota sets the quotas that limit uploads; nil disables them
+func (us *UploadService) SetQuota(quota *QuotaService) {
+	us.quota = quota
+}
+
+// checkCollisionPolicy validates a collision policy and rename suffix; empty values inherit defaults
+func checkCollisionPolicy(policy, suffix string) error {
+	switch policy {
+	case "", config.CollisionReject, config.CollisionOverwrite, config.CollisionRename:
+	default:
+		return fmt.Errorf("invalid collision policy %q", policy)
+	}
+	switch suffix {
+	case "", config.SuffixNumeric, config.SuffixTimestamp:
+	default:
+		return fmt.Errorf("invalid rename suffix %q", suffix)
+	}
+	return nil
+}
+
+// Validate checks the declared size, name and extension of an upload before any bytes are read
+func (us *UploadService) Validate(req UploadRequest) error {
+	if req.Size > us.config.Storage.MaxUploadSize {
+		return ErrFileTooLarge
+	}
+
+	filename := utils.SanitizeFilename(req.Filename)
+	if filename == "" {
+		return ErrInvalidFilename
+	}
+
+	allowed := us.config.Security.AllowedExtensions
+	if req.AllowedExtensions != nil {
+		allowed = req.AllowedExtensions
+	}
+	if !req.Approved && !isAllowedExtension(filepath.Ext(filename), allowed) {
+		return ErrExtensionNotAllowed
+	}
+
+	// Fail early instead of after receiving the whole file, unless a
+	// reservation has claimed the room already
+	dir := us.destDir(req)
+	if req.Reservation == nil {
+		room, exceeded := us.room(req, dir)
+		if exceeded != nil && (room <= 0 || req.Size > room) {
+			return exceeded
+		}
+	}
+	if policy, _ := us.collisionPolicy(dir); policy == config.CollisionReject {
+		if _, err := os.Lstat(filepath.Join(dir, filename)); err == nil {
+			return ErrFileExists
+		}
+	}
+
+	return nil
+}
+
+// Store validates an upload and writes it to its destination directory.
+// Data goes to a hidden temporary file first, so a failed upload never
+// replaces or leaves behind a file under the final name.
+func (us *UploadService) Store(src io.Reader, req UploadRequest) (*StoredFile, error) {
+	if err := us.Validate(req); err != nil {
+		return nil, err
+	}
+
+	dir := us.destDir(req)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	// Refuse disallowed content before receiving the rest of the file
+	head, quarantine, err := us.sniff(src, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Hold quota for the declared size; the reservation grows if more arrives
+	declared := req.Size
+	if declared < 0 {
+		declared = 0
+	}
+	reservation, err := us.reserve(req, dir, declared)
+	if err != nil {
+		return nil, err
+	}
+	defer reservation.Release()
+
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	// Copy file content, enforcing the size limit and quotas as bytes arrive
+	// since the declared size may be unknown or wrong, and hash it on the way
+	digests := newUploadDigests(req.Expected)
+	maxSize := us.config.Storage.MaxUploadSize
+	body := io.MultiReader(bytes.NewReader(head), src)
+	written, err := io.Copy(io.MultiWriter(reservation, tmp, digests), io.LimitReader(body, maxSize+1))
+	if err == nil && written > maxSize {
+		err = ErrFileTooLarge
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = digests.verify(req.Expected)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if quarantine != nil {
+		return nil, us.quarantineUpload(tmp.Name(), req, quarantine)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return nil, err
+	}
+
+	return us.finish(tmp.Name(), dir, req, written, digests.sha256Hex())
+}
+
+// Commit moves a completed temporary file to its destination directory
+func (us *UploadService) Commit(tmpPath string, req UploadRequest) (*StoredFile, error) {
+	if err := us.Validate(req); err != nil {
+		return nil, err
+	}
+
+	dir := us.destDir(req)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	// The data arrived in pieces, so it is checked and hashed once complete
+	f, err := os.Open(tmpPath)
+	if err != nil {
+		return nil, err
+	}
+	head, quarantine, err := us.sniff(f, req)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	digests := newUploadDigests(req.Expected)
+	digests.Write(head)
+	rest, err := io.Copy(digests, f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	size := int64(len(head)) + rest
+	if err := digests.verify(req.Expected); err != nil {
+		return nil, err
+	}
+	if quarantine != nil {
+		return nil, us.quarantineUpload(tmpPath, req, quarantine)
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		return nil, err
+	}
+
+	// The bytes are already on disk, so they are claimed as written
+	reservation := req.Reservation
+	if reservation == nil {
+		if reservation, err = us.reserve(req, dir, 0); err != nil {
+			return nil, err
+		}
+		defer reservation.Release()
+	}
+	if err := reservation.settle(size); err != nil {
+		return nil, err
+	}
+
+	stored, err := us.finish(tmpPath, dir, req, size, digests.sha256Hex())
+	if err != nil {
+		return nil, err
+	}
+	us.untrack(tmpPath)
+	return stored, nil
+}
+
+// Quarantine moves a file into the quarantine directory under a unique
+// variant of name and returns its new path
+func (us *UploadService) Quarantine(srcPath, name string) (string, error) {
+	dir := us.config.Storage.QuarantineDir
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	prefix, err := utils.RandomToken(4)
+	if err != nil {
+		return "", err
+	}
+	destPath := filepath.Join(dir, utils.TruncateUTF8(prefix+"-"+name, utils.MaxFilenameBytes))
+	if err := os.Rename(srcPath, destPath); err != nil {
+		return "", err
+	}
+	us.untrack(srcPath)
+	return destPath, nil
+}
+
+// Remove deletes a stored file
+func (us *UploadService) Remove(path string) error {
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	us.untrack(path)
+	return nil
+}
+
+// room returns the bytes the quotas leave to an upload, and the error to
+// report if it stores more; the error is nil without quotas
+func (us *UploadService) room(req UploadRequest, dir string) (int64, error) {
+	if us.quota == nil {
+		return math.MaxInt64, nil
+	}
+	return us.quota.Room(quotaUploader(req), dir)
+}
+
+// reserve holds quota for an upload while it is written; the reservation is
+// nil without quotas
+func (us *UploadService) reserve(req UploadRequest, dir string, size int64) (*QuotaReservation, error) {
+	if us.quota == nil {
+		return nil, nil
+	}
+	return us.quota.Reserve(quotaUploader(req), dir, size)
+}
+
+// quotaUploader returns the user whose quota an upload counts against, or ""
+// for approved files, which their uploader's quota already counts
+func quotaUploader(req UploadRequest) string {
+	if req.Approved {
+		return ""
+	}
+	return req.Uploader
+}
+
+// untrack tells the trackers that a stored file is gone. Temporary files
+// were never tracked, so untracking them does nothing.
+func (us *UploadService) untrack(path string) {
+	for _, tracker := range us.trackers {
+		if removal, ok := tracker.(RemovalTracker); ok {
+			removal.Untrack(path)
+		}
+	}
+}
+
+// sniff reads the first bytes of an upload and checks its content type.
+// Disallowed content is refused with an error. Content that contradicts the
+// extension is refused too, unless the mismatch policy allows it or says to
+// quarantine it; in the latter case the mismatch is returned as quarantine so
+// the caller can read the rest of the file first.
+func (us *UploadService) sniff(src io.Reader, req UploadRequest) (head []byte, quarantine error, err error) {
+	head = make([]byte, sniffLen)
+	n, err := io.ReadFull(src, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, nil, err
+	}
+	head = head[:n]
+
+	if req.Approved {
+		return head, nil, nil
+	}
+
+	err = checkContent(head, filepath.Ext(utils.SanitizeFilename(req.Filename)), us.config.Security.AllowedMimeTypes)
+	if errors.Is(err, ErrContentMismatch) {
+		switch us.config.Security.ContentMismatch {
+		case config.MismatchAllow:
+			return head, nil, nil
+		case config.MismatchQuarantine:
+			return head, err, nil
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return head, nil, nil
+}
+
+// quarantineUpload moves a refused upload into quarantine and returns the error to report
+func (us *UploadService) quarantineUpload(path string, req UploadRequest, reason error) error {
+	quarantined, err := us.Quarantine(path, utils.SanitizeFilename(req.Filename))
+	if err != nil {
+		return err
+	}
+	return &QuarantinedError{Reason: reason, Path: quarantined}
+}
+
+// finish scans a completed file, places it and tells the trackers about it
+func (us *UploadService) finish(tmpPath, dir string, req UploadRequest, size int64, sha256Hex string) (*StoredFile, error) {
+	scan, err := us.scan(tmpPath, req)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := us.place(tmpPath, dir, utils.SanitizeFilename(req.Filename), size)
+	if err != nil {
+		return nil, err
+	}
+	stored.SHA256 = sha256Hex
+	stored.Scan = scan
+
+	for _, tracker := range us.trackers {
+		tracker.Track(stored, req)
+	}
+	return stored, nil
+}
+
+// scan checks a completed file for malware before it becomes visible.
+// Infected files are quarantined. Files that could not be scanned are refused
+// with ErrScanFailed, or stored as unscanned if the failure policy is fail open.
+// Approved files are scanned again, as signatures may have been updated since.
+func (us *UploadService) scan(path string, req UploadRequest) (*ScanResult, error) {
+	if us.scanner == nil {
+		return nil, nil
+	}
+
+	ctx := context.Background()
+	if timeout := us.config.Scan.Timeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	result, err := us.scanner.Scan(ctx, path)
+	if err != nil {
+		if us.scanFailed != nil {
+			us.scanFailed(utils.SanitizeFilename(req.Filename), err)
+		}
+		if us.config.Scan.FailurePolicy == config.ScanFailOpen {
+			return &ScanResult{Verdict: ScanUnscanned, Scanner: us.config.Scan.Scanner}, nil
+		}
+		return nil, fmt.Errorf("%w: %v", ErrScanFailed, err)
+	}
+	if result.Verdict == ScanInfected {
+		return nil, us.quarantineUpload(path, req, fmt.Errorf("%w: %s", ErrInfected, result.Signature))
+	}
+	return result, nil
+}
+
+// uploadDigests hashes upload data as it is written. MD5 is only computed
+// when the client sent one to compare against.
+type uploadDigests struct {
+	sha256 hash.Hash
+	md5    hash.Hash
+}
+
+func newUploadDigests(expected Checksums) *uploadDigests {
+	d := &uploadDigests{sha256: sha256.New()}
+	if expected.MD5 != nil {
+		d.md5 = md5.New()
+	}
+	return d
+}
+
+func (d *uploadDigests) Write(p []byte) (int, error) {
+	d.sha256.Write(p)
+	if d.md5 != nil {
+		d.md5.Write(p)
+	}
+	return len(p), nil
+}
+
+// verify compares the digests with the expected ones
+func (d *uploadDigests) verify(expected Checksums) error {
+	if expected.SHA256 != nil && !bytes.Equal(d.sha256.Sum(nil), expected.SHA256) {
+		return ErrChecksumMismatch
+	}
+	if expected.MD5 != nil && !bytes.Equal(d.md5.Sum(nil), expected.MD5) {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
+func (d *uploadDigests) sha256Hex() string {
+	return hex.EncodeToString(d.sha256.Sum(nil))
+}
+
+// place renames a finished file into dir, applying the collision policy of
+// the directory. Names are claimed with O_EXCL so concurrent uploads of the
+// same name cannot overwrite each other unless the policy says so.
+func (us *UploadService) place(tmpPath, dir, filename string, size int64) (*StoredFile, error) {
+	policy, suffix := us.collisionPolicy(dir)
+
+	name := filename
+	if policy != config.CollisionOverwrite {
+		now := time.Now()
+		for attempt := 0; ; attempt++ {
+			if attempt > 0 && policy == config.CollisionReject {
+				return nil, ErrFileExists
+			}
+			if attempt >= maxRenameAttempts {
+				return nil, ErrFileExists
+			}
+
+			name = renameCandidate(filename, suffix, attempt, now)
+			f, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+			if err == nil {
+				f.Close()
+				break
+			}
+			if !os.IsExist(err) {
+				return nil, err
+			}
+		}
+	}
+
+	destPath := filepath.Join(dir, name)
+	if err := os.Rename(tmpPath, destPath); err != nil {
+		if policy != config.CollisionOverwrite {
+			os.Remove(destPath)
+		}
+		return nil, err
+	}
+
+	return &StoredFile{
+		Name: name,
+		Path: destPath,
+		Size: size,
+	}, nil
+}
+
+// renameCandidate returns the name to try on the given attempt; attempt 0 is the name itself
+func renameCandidate(filename, suffix string, attempt int, now time.Time) string {
+	if attempt == 0 {
+		return filename
+	}
+
+	var tag string
+	switch {
+	case suffix == config.SuffixTimestamp && attempt == 1:
+		tag = "-" + now.Format("20060102-150405")
+	case suffix == config.SuffixTimestamp:
+		tag = fmt.Sprintf("-%s-%d", now.Format("20060102-150405"), attempt)
+	default:
+		tag = fmt.Sprintf(" (%d)", attempt)
+	}
+
+	ext := filepath.Ext(filename)
+	base := strings.TrimSuffix(filename, ext)
+	if base == "" {
+		// Names such as ".env" have no base to put the suffix on
+		base, ext = filename, ""
+	}
+	return utils.TruncateUTF8(base, utils.MaxFilenameBytes-len(tag)-len(ext)) + tag + ext
+}
+
+// destDir returns the destination directory of an upload
+func (us *UploadService) destDir(req UploadRequest) string {
+	if req.Dir != "" {
+		return req.Dir
+	}
+	return us.config.Storage.IncomingDir
+}
+
+// collisionPolicy returns the collision policy and rename suffix of a
+// directory. Rule paths are relative to the upload directory and may contain
+// glob segments; the rule with the most segments wins.
+func (us *UploadService) collisionPolicy(dir string) (string, string) {
+	collision := us.config.Storage.Collision
+	policy, suffix := collision.Policy, collision.Suffix
+
+	rel, ok := utils.RelativeTo(us.config.Storage.UploadDir, dir)
+	if !ok {
+		return policy, suffix
+	}
+	dirSegments := splitACLPath(rel)
+
+	best := -1
+	for _, rule := range collision.Rules {
+		ruleSegments := splitACLPath(rule.Path)
+		if len(ruleSegments) > len(dirSegments) || len(ruleSegments) <= best {
+			continue
+		}
+		if !matchSegments(ruleSegments, dirSegments[:len(ruleSegments)]) {
+			continue
+		}
+
+		best = len(ruleSegments)
+		policy, suffix = collision.Policy, collision.Suffix
+		if rule.Policy != "" {
+			policy = rule.Policy
+		}
+		if rule.Suffix != "" {
+			suffix = rule.Suffix
+		}
+	}
+
+	return policy, suffix
+}
+
+// isAllowedExtension checks if the file extension is allowed
+func isAllowedExtension(ext string, allowedExtensions []string) bool {
+	if len(allowedExtensions) == 0 {
+		return true // If no restriction, allow all extensions
+	}
+
+	for _, allowed := range allowedExtensions {
+		if strings.EqualFold(ext, allowed) {
+			return true
+		}
+	}
+	return false
+}
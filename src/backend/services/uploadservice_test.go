@@ -0,0 +1,65 @@
+package services
+
+import (
+	"path/filepath"
+	"simple-server/src/backend/config"
+	"testing"
+)
+
+func TestCollisionPolicy(t *testing.T) {
+	uploadDir := t.TempDir()
+	cfg := &config.Config{
+		Storage: config.StorageConfig{
+			UploadDir: uploadDir,
+			Collision: config.CollisionConfig{
+				Policy: config.CollisionRename,
+				Suffix: config.SuffixNumeric,
+				Rules: []config.CollisionRule{
+					{Path: "incoming/drop", Policy: config.CollisionReject},
+					{Path: "projects/*", Policy: config.CollisionOverwrite},
+					{Path: "projects/*/releases", Suffix: config.SuffixTimestamp},
+				},
+			},
+		},
+	}
+	us, err := NewUploadService(cfg)
+	if err != nil {
+		t.Fatalf("NewUploadService: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		dir    string
+		policy string
+		suffix string
+	}{
+		{name: "upload dir", dir: uploadDir, policy: config.CollisionRename, suffix: config.SuffixNumeric},
+		{name: "no rule", dir: filepath.Join(uploadDir, "incoming"), policy: config.CollisionRename, suffix: config.SuffixNumeric},
+		{name: "exact rule", dir: filepath.Join(uploadDir, "incoming", "drop"), policy: config.CollisionReject, suffix: config.SuffixNumeric},
+		{name: "below rule", dir: filepath.Join(uploadDir, "incoming", "drop", "box1"), policy: config.CollisionReject, suffix: config.SuffixNumeric},
+		{name: "glob", dir: filepath.Join(uploadDir, "projects", "team-b"), policy: config.CollisionOverwrite, suffix: config.SuffixNumeric},
+		{name: "glob parent", dir: filepath.Join(uploadDir, "projects"), policy: config.CollisionRename, suffix: config.SuffixNumeric},
+		{name: "deeper rule keeps default policy", dir: filepath.Join(uploadDir, "projects", "team-b", "releases"), policy: config.CollisionRename, suffix: config.SuffixTimestamp},
+		{name: "outside upload dir", dir: t.TempDir(), policy: config.CollisionRename, suffix: config.SuffixNumeric},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, suffix := us.collisionPolicy(tt.dir)
+			if policy != tt.policy || suffix != tt.suffix {
+				t.Errorf("collisionPolicy(%q) = %q %q, want %q %q", tt.dir, policy, suffix, tt.policy, tt.suffix)
+			}
+		})
+	}
+}
+
+func TestNewUploadServiceCollisionRules(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Storage.Collision = config.CollisionConfig{
+		Policy: config.CollisionRename,
+		Rules:  []config.CollisionRule{{Path: "x", Policy: "replace"}},
+	}
+	if _, err := NewUploadService(cfg); err == nil {
+		t.Error("NewUploadService accepted an invalid collision rule policy")
+	}
+}
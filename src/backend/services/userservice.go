@@ -0,0 +1,128 @@
+package services
+
+import (
+	"errors"
+	"simple-server/src/backend/config"
+	"simple-server/src/backend/utils"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrInvalidCredentials is returned when a username or password does not match
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+// dummyHash is compared against when the user does not exist, so that
+// unknown usernames take as long to reject as wrong passwords
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+
+type User struct {
+	Username     string   `json:"username"`
+	PasswordHash string   `json:"passwordHash"`
+	Groups       []string `json:"groups,omitempty"`
+	Admin        bool     `json:"admin,omitempty"`
+}
+
+// usersFile is the on-disk format of the users file
+type usersFile struct {
+	Users []User `json:"users"`
+}
+
+type UserService struct {
+	config *config.Config
+	mu     sync.RWMutex
+	users  map[string]*User
+}
+
+func NewUserService(cfg *config.Config) (*UserService, error) {
+	us := &UserService{
+		config: cfg,
+	}
+	if err := us.Reload(); err != nil {
+		return nil, err
+	}
+	return us, nil
+}
+
+// Reload reads the users file and merges the users defined in config
+func (us *UserService) Reload() error {
+	var file usersFile
+	if us.config.Auth.UsersFile != "" {
+		if err := utils.ReadJSONFile(us.config.Auth.UsersFile, &file); err != nil {
+			return err
+		}
+	}
+
+	users := make(map[string]*User)
+	for i := range file.Users {
+		user := file.Users[i]
+		users[user.Username] = &user
+	}
+
+	// Users defined in config take precedence over the users file
+	for _, u := range us.config.Auth.Users {
+		users[u.Username] = &User{
+			Username:     u.Username,
+			PasswordHash: u.PasswordHash,
+			Groups:       u.Groups,
+			Admin:        u.Admin,
+		}
+	}
+
+	us.mu.Lock()
+	us.users = users
+	us.mu.Unlock()
+
+	return nil
+}
+
+// GetUser looks up a user by name
+func (us *UserService) GetUser(username string) (*User, bool) {
+	us.mu.RLock()
+	defer us.mu.RUnlock()
+
+	user, ok := us.users[username]
+	return user, ok
+}
+
+// Count returns the number of known users
+func (us *UserService) Count() int {
+	us.mu.RLock()
+	defer us.mu.RUnlock()
+
+	return len(us.users)
+}
+
+// Authenticate checks a username and password against the stored bcrypt hash
+func (us *UserService) Authenticate(username, password string) (*User, error) {
+	user, ok := us.GetUser(username)
+	if !ok || user.PasswordHash == "" {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return user, nil
+}
+
+// Identity converts a user into a request identity
+func (u *User) Identity(method string) *utils.Identity {
+	return &utils.Identity{
+		Username: u.Username,
+		Groups:   u.Groups,
+		Admin:    u.Admin,
+		Method:   method,
+	}
+}
+
+// HashPassword generates a bcrypt hash suitable for the users file
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+)
+
+const auditContextKey = "audit"
+
+// AuditInfo carries the details handlers add to the audit record of a request
+type AuditInfo struct {
+	Path   string
+	Size   int64
+	Detail string
+}
+
+// Audit returns the audit details of the request, creating them on first use
+func Audit(c *gin.Context) *AuditInfo {
+	if value, ok := c.Get(auditContextKey); ok {
+		return value.(*AuditInfo)
+	}
+
+	info := &AuditInfo{}
+	c.Set(auditContextKey, info)
+	return info
+}
+
+// AuditPath formats a file path for the audit log relative to the upload
+// directory, falling back to the full path for files outside of it
+func AuditPath(uploadDir, fullPath string) string {
+	if rel, ok := RelativeTo(uploadDir, fullPath); ok {
+		return "/" + filepath.ToSlash(rel)
+	}
+	return fullPath
+}
@@ -0,0 +1,19 @@
+//go:build windows
+
+package utils
+
+import "golang.org/x/sys/windows"
+
+// DiskFree returns the bytes available to the current user on the volume holding path
+func DiskFree(path string) (uint64, error) {
+	name, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var available uint64
+	if err := windows.GetDiskFreeSpaceEx(name, &available, nil, nil); err != nil {
+		return 0, err
+	}
+	return available, nil
+}
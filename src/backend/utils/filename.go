@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxFilenameBytes is the name length limit of common filesystems
+const MaxFilenameBytes = 255
+
+// windowsReservedNames cannot be used as file names on Windows, with or without extension
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// SanitizeFilename turns a client-supplied file name into a safe one: path
+// components and leading dots are stripped, the name is normalized to Unicode
+// NFC, control and reserved characters are replaced, Windows device names are
+// escaped and the result is shortened to 255 bytes keeping the extension. It
+// returns "" if nothing usable is left.
+func SanitizeFilename(name string) string {
+	// Clients on Windows may send backslash-separated paths
+	name = strings.ReplaceAll(name, "\\", "/")
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	name = norm.NFC.String(strings.ToValidUTF8(name, ""))
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsControl(r):
+			return -1
+		case strings.ContainsRune(`<>:"|?*`, r):
+			return '_'
+		}
+		return r
+	}, name)
+
+	// Trailing dots and spaces are dropped by Windows, and leading dots would
+	// hide the file and let it clash with internal names such as .tus
+	name = strings.TrimLeft(strings.TrimRight(name, ". "), ". ")
+	if name == "" {
+		return ""
+	}
+
+	base := name
+	if i := strings.Index(base, "."); i >= 0 {
+		base = base[:i]
+	}
+	if windowsReservedNames[strings.ToUpper(strings.TrimSpace(base))] {
+		name = "_" + name
+	}
+
+	return truncateFilename(name, MaxFilenameBytes)
+}
+
+// truncateFilename shortens a name to max bytes, keeping the extension if it
+// is reasonably short
+func truncateFilename(name string, max int) string {
+	if len(name) <= max {
+		return name
+	}
+
+	ext := filepath.Ext(name)
+	if len(ext) > 16 {
+		ext = ""
+	}
+	return TruncateUTF8(name[:len(name)-len(ext)], max-len(ext)) + ext
+}
+
+// TruncateUTF8 shortens s to at most max bytes without splitting a rune
+func TruncateUTF8(s string, max int) string {
+	for len(s) > max {
+		_, size := utf8.DecodeLastRuneInString(s)
+		s = s[:len(s)-size]
+	}
+	return s
+}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	long := strings.Repeat("a", 300)
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "plain", in: "report.pdf", want: "report.pdf"},
+		{name: "unix path", in: "../../etc/passwd", want: "passwd"},
+		{name: "windows path", in: `C:\Users\bob\notes.txt`, want: "notes.txt"},
+		{name: "leading dot", in: ".htaccess", want: "htaccess"},
+		{name: "leading dots", in: "..x.txt", want: "x.txt"},
+		{name: "internal name", in: ".tus", want: "tus"},
+		{name: "trailing dots and spaces", in: "file.txt. . ", want: "file.txt"},
+		{name: "reserved characters", in: `a<b>c:d"e|f?g*.txt`, want: "a_b_c_d_e_f_g_.txt"},
+		{name: "control characters", in: "a\x00b\tc\n.txt", want: "abc.txt"},
+		{name: "invalid utf-8", in: "a\xffb.txt", want: "ab.txt"},
+		{name: "nfc", in: "cafe\u0301.txt", want: "caf\u00e9.txt"},
+		{name: "device name", in: "CON", want: "_CON"},
+		{name: "device name with extension", in: "nul.txt", want: "_nul.txt"},
+		{name: "device name prefix", in: "CONSOLE.txt", want: "CONSOLE.txt"},
+		{name: "truncated", in: long + ".txt", want: long[:MaxFilenameBytes-4] + ".txt"},
+		{name: "empty", in: "", want: ""},
+		{name: "only dots", in: "...", want: ""},
+		{name: "directory", in: "dir/", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeFilename(tt.in); got != tt.want {
+				t.Errorf("SanitizeFilename(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	// "é" is two bytes; cutting inside it must drop the whole rune
+	if got := TruncateUTF8("abé", 3); got != "ab" {
+		t.Errorf("TruncateUTF8 = %q, want %q", got, "ab")
+	}
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const identityContextKey = "identity"
+
+// AnonymousUser is the name used for requests without an identity
+const AnonymousUser = "anonymous"
+
+// Identity describes the authenticated caller of a request
+type Identity struct {
+	Username string   `json:"username"`
+	Groups   []string `json:"groups,omitempty"`
+	Admin    bool     `json:"admin"`
+	Method   string   `json:"method"`
+	// Scopes and PathPrefixes restrict API token identities; nil means unrestricted
+	Scopes       []string `json:"scopes,omitempty"`
+	PathPrefixes []string `json:"pathPrefixes,omitempty"`
+}
+
+// HasScope checks if the identity may perform operations of the given scope.
+// The admin scope implies all others but requires an admin identity.
+func (id *Identity) HasScope(scope string) bool {
+	if scope == "admin" && !id.Admin {
+		return false
+	}
+	if id.Scopes == nil {
+		return true
+	}
+	for _, s := range id.Scopes {
+		if s == scope || (s == "admin" && id.Admin) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsPath checks if a slash-separated path is within the identity's path prefixes
+func (id *Identity) AllowsPath(path string) bool {
+	if len(id.PathPrefixes) == 0 {
+		return true
+	}
+	for _, prefix := range id.PathPrefixes {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// InGroup checks if the identity belongs to a group
+func (id *Identity) InGroup(group string) bool {
+	for _, g := range id.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// SetIdentity stores the authenticated identity in the request context
+func SetIdentity(c *gin.Context, identity *Identity) {
+	c.Set(identityContextKey, identity)
+}
+
+// GetIdentity returns the authenticated identity, or nil for anonymous requests
+func GetIdentity(c *gin.Context) *Identity {
+	value, ok := c.Get(identityContextKey)
+	if !ok {
+		return nil
+	}
+	identity, _ := value.(*Identity)
+	return identity
+}
+
+// IdentityName returns the username of the caller, or AnonymousUser
+func IdentityName(c *gin.Context) string {
+	if identity := GetIdentity(c); identity != nil {
+		return identity.Username
+	}
+	return AnonymousUser
+}
@@ -0,0 +1,55 @@
+package utils
+
+import "testing"
+
+func TestIdentityHasScope(t *testing.T) {
+	tests := []struct {
+		name     string
+		identity Identity
+		scope    string
+		want     bool
+	}{
+		{name: "unrestricted", identity: Identity{}, scope: "upload", want: true},
+		{name: "unrestricted admin scope needs admin", identity: Identity{}, scope: "admin", want: false},
+		{name: "unrestricted admin", identity: Identity{Admin: true}, scope: "admin", want: true},
+		{name: "granted scope", identity: Identity{Scopes: []string{"read", "upload"}}, scope: "upload", want: true},
+		{name: "missing scope", identity: Identity{Scopes: []string{"read"}}, scope: "delete", want: false},
+		{name: "empty scopes", identity: Identity{Scopes: []string{}}, scope: "read", want: false},
+		{name: "admin scope implies others", identity: Identity{Admin: true, Scopes: []string{"admin"}}, scope: "delete", want: true},
+		{name: "admin scope of a non-admin", identity: Identity{Scopes: []string{"admin"}}, scope: "delete", want: false},
+		{name: "admin without admin scope", identity: Identity{Admin: true, Scopes: []string{"read"}}, scope: "admin", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.identity.HasScope(tt.scope); got != tt.want {
+				t.Errorf("HasScope(%q) = %v, want %v", tt.scope, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIdentityAllowsPath(t *testing.T) {
+	identity := Identity{PathPrefixes: []string{"builds", "shared/ci"}}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{path: "builds", want: true},
+		{path: "builds/42/app.zip", want: true},
+		{path: "shared/ci/log.txt", want: true},
+		{path: "builds2", want: false},
+		{path: "shared", want: false},
+		{path: "", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := identity.AllowsPath(tt.path); got != tt.want {
+			t.Errorf("AllowsPath(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+	if !(&Identity{}).AllowsPath("anything") {
+		t.Error("identity without prefixes restricted to a path")
+	}
+}
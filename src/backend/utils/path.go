@@ -39,6 +39,25 @@ func IsValidPath(basePath, requestPath string) bool {
 	return strings.HasPrefix(absFull, absBase)
 }
 
+// RelativeTo returns the path of fullPath relative to basePath, and whether
+// fullPath is inside basePath at all
+func RelativeTo(basePath, fullPath string) (string, bool) {
+	absBase, err := filepath.Abs(basePath)
+	if err != nil {
+		return "", false
+	}
+	absFull, err := filepath.Abs(fullPath)
+	if err != nil {
+		return "", false
+	}
+
+	rel, err := filepath.Rel(absBase, absFull)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
 // IsHiddenFile checks if the filename is a hidden file (starts with .)
 func IsHiddenFile(filename string) bool {
 	return strings.HasPrefix(filename, ".")
@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// TokenBucket is a token bucket rate limiter. Tokens refill continuously at
+// rate per second up to burst.
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	return &TokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Allow takes one token if available. Otherwise it returns how long to wait
+// until a token will be available.
+func (b *TokenBucket) Allow() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refillLocked(time.Now())
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	return false, time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// WaitN blocks until n tokens have been taken or the context is done.
+// Requests larger than the burst are served in burst-sized portions.
+func (b *TokenBucket) WaitN(ctx context.Context, n int) error {
+	remaining := float64(n)
+	for remaining > 0 {
+		b.mu.Lock()
+		b.refillLocked(time.Now())
+
+		take := remaining
+		if take > b.burst {
+			take = b.burst
+		}
+
+		var wait time.Duration
+		if b.tokens >= take {
+			b.tokens -= take
+			remaining -= take
+		} else {
+			wait = time.Duration((take - b.tokens) / b.rate * float64(time.Second))
+		}
+		b.mu.Unlock()
+
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+	}
+	return nil
+}
+
+// Full reports whether the bucket is full, i.e. indistinguishable from a new one
+func (b *TokenBucket) Full() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refillLocked(time.Now())
+	return b.tokens >= b.burst
+}
+
+// refillLocked adds the tokens accumulated since the last update. Callers must hold b.mu.
+func (b *TokenBucket) refillLocked(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+
+	b.tokens += elapsed * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+}